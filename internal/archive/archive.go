@@ -0,0 +1,135 @@
+// Package archive groups dated nodes by year and month
+package archive
+
+import (
+	"log/slog"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/nodepathlink"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+var Logger *slog.Logger
+
+// Year holds the dated nodes of a year, newest month first.
+//
+// * Href: link to the page of the year, empty if years have no page
+type Year struct {
+	Href   string
+	Months []Month
+	Year   int
+}
+
+// Month holds the dated nodes of a month, newest first.
+//
+// * Name: full name of the month, e.g. "January"
+type Month struct {
+	Name   string
+	Links  []nodepathlink.NodePathLink
+	Number int
+}
+
+// Construct groups the dated nodes by year and month, newest first. Drafts
+// and unlisted nodes are left out, and nodes rendered as part of a log
+// nodegroup link to the log. Nodes with invalid dates are skipped with a
+// warning.
+func Construct(s *state.State) []Year {
+	years := make(map[int]map[int][]nodepathlink.NodePathLink)
+
+	for _, np := range s.NodePaths {
+		child := Logger.With(slog.Any("nodepath", np), slog.String("context", "archive"))
+
+		entry := s.NodeMap[np]
+		md := entry.Metadata
+
+		if md.Hidden() || md.Unlisted || len(md.Date) == 0 {
+			continue
+		}
+
+		date, err := convert.ParseDate(md.Date, s.Config.Location)
+		if err != nil {
+			child.Warn("skipping: invalid date", slog.String("date", md.Date))
+
+			continue
+		}
+
+		l := nodepathlink.NodePathLink{
+			Href:        entry.Href,
+			Title:       convert.Title(md.Title, np.Base()),
+			Description: md.Description,
+			Tags:        md.Tags,
+			IsDir:       np.Base() == "index",
+			Keys:        nodepathlink.Keys{Date: date, Filename: filepath.Base(np.String())},
+		}
+
+		l.Date, l.MachineDate, _ = convert.Date(md.Date, s.Config.Location, s.Config.DateFormat)
+
+		// Entries of logs aren't rendered on their own
+		if s.SkippedNodePathMap[np] {
+			index := nodepath.NodePath(filepath.Join(filepath.Dir(np.String()), "index.md"))
+			l.Href = s.NodeMap[index].Href
+		}
+
+		if years[date.Year()] == nil {
+			years[date.Year()] = make(map[int][]nodepathlink.NodePathLink)
+		}
+
+		years[date.Year()][int(date.Month())] = append(years[date.Year()][int(date.Month())], l)
+
+		child.Debug("archive entry created")
+	}
+
+	archive := []Year{}
+
+	for y, months := range years {
+		year := Year{Year: y, Href: yearHref(s, y)}
+
+		for m, links := range months {
+			_ = nodepathlink.Sort(links, "-date")
+
+			year.Months = append(year.Months, Month{
+				Name:   time.Month(m).String(),
+				Number: m,
+				Links:  links,
+			})
+		}
+
+		sort.Slice(year.Months, func(i, j int) bool { return year.Months[i].Number > year.Months[j].Number })
+
+		archive = append(archive, year)
+	}
+
+	sort.Slice(archive, func(i, j int) bool { return archive[i].Year > archive[j].Year })
+
+	return archive
+}
+
+// OutPath returns the output path of the page of year y, or of the archive
+// if y is 0
+func OutPath(s *state.State, y int) string {
+	if y == 0 {
+		return filepath.Join(s.OutputDir, "archive.html")
+	}
+
+	return filepath.Join(s.OutputDir, "archive", strconv.Itoa(y)+".html")
+}
+
+// yearHref returns the link to the page of year y, empty if disabled
+func yearHref(s *state.State, y int) string {
+	if !s.Config.Archive.Years {
+		return ""
+	}
+
+	href := path.Join(s.Config.Path, "archive", strconv.Itoa(y))
+	if s.Config.IsExt {
+		href += ".html"
+	}
+
+	return href
+}
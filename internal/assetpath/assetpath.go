@@ -1,8 +1,34 @@
 // Package assetpath defines the assetpath type
 package assetpath
 
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
 type AssetPath string
 
 func (ap AssetPath) String() string {
 	return string(ap)
 }
+
+// Resolve returns the absolute path of dest, a link to an asset as written in
+// a source file in dir. dest is URL-unescaped if it can be, and resolved
+// against inputDir if it starts with a slash.
+func Resolve(inputDir string, dir string, dest string) (AssetPath, error) {
+	if unescaped, err := url.PathUnescape(dest); err == nil {
+		dest = unescaped
+	}
+
+	if strings.HasPrefix(dest, "/") {
+		dir = inputDir
+	}
+
+	p, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(dest)))
+	if err != nil {
+		return "", err
+	}
+
+	return AssetPath(p), nil
+}
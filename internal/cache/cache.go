@@ -0,0 +1,266 @@
+// Package cache persists build results between runs so unchanged sources
+// aren't reconverted and unchanged pages aren't retemplated.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mstcl/pher/v3/internal/mathml"
+	"github.com/mstcl/pher/v3/internal/metadata"
+	"github.com/mstcl/pher/v3/internal/source"
+	"github.com/mstcl/pher/v3/internal/toc"
+)
+
+// version is bumped whenever the layout of Cache or Entry changes
+const version = 7
+
+const filename = "cache.gob"
+
+// Entry holds the results of processing a single source file.
+//
+// * SourceHash: hash of the raw source file contents
+//
+// * Images: stamps of the images the body depends on (key: image path)
+//
+// * Resolved: sources the wikilinks of the body resolved to (key: target)
+//
+// * Unsupported: TeX commands of math in the body that couldn't be rendered
+//
+// * TOC: table of contents of the body
+type Entry struct {
+	Images      map[string]string
+	Resolved    map[string]string
+	SourceHash  string
+	Body        []byte
+	ChromaCSS   []byte
+	Unsupported []mathml.Unsupported
+	TOC         *toc.TOC
+	Links       source.Links
+	Metadata    metadata.Metadata
+}
+
+// Cache is the persisted build cache.
+//
+// * Entries: processed sources (key: nodepath)
+//
+// * Outputs: files written to the output directory by the previous build
+// (key: output path, value: hash of whatever produced it)
+type Cache struct {
+	Entries      map[string]Entry
+	Outputs      map[string]string
+	ConfigHash   string
+	TemplateHash string
+	Version      int
+
+	// written records outputs produced by this build
+	written map[string]string
+	// visited records entries looked up or stored by this build
+	visited map[string]bool
+	dir     string
+	mu      sync.Mutex
+}
+
+// Hash returns a hex-encoded digest of all of b
+func Hash(b ...[]byte) string {
+	h := sha256.New()
+	for _, v := range b {
+		h.Write(v)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Stamp identifies the current version of the file at p by its modification
+// time and size. It returns an empty string if p can't be stat'd.
+func Stamp(p string) string {
+	info, err := os.Stat(p)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprint(info.ModTime().UnixNano(), info.Size())
+}
+
+// Load reads the cache in dir. A missing or outdated cache is not an error:
+// an empty cache is returned instead. Entries are dropped if the
+// configuration changed, and outputs are dropped if either the configuration
+// or the templates changed.
+func Load(dir string, configHash string, templateHash string) (*Cache, error) {
+	c := &Cache{
+		Entries:      make(map[string]Entry),
+		Outputs:      make(map[string]string),
+		ConfigHash:   configHash,
+		TemplateHash: templateHash,
+		Version:      version,
+		written:      make(map[string]string),
+		visited:      make(map[string]bool),
+		dir:          dir,
+	}
+
+	f, err := os.Open(filepath.Join(dir, filename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("os.Open %s: %w", dir, err)
+	}
+	defer f.Close()
+
+	var prev Cache
+	if err := gob.NewDecoder(f).Decode(&prev); err != nil || prev.Version != version {
+		// unreadable caches are simply rebuilt
+		return c, nil
+	}
+
+	// outputs are always kept so stale files can still be pruned
+	if prev.Outputs != nil {
+		c.Outputs = prev.Outputs
+	}
+
+	if prev.ConfigHash != configHash {
+		for k := range c.Outputs {
+			c.Outputs[k] = ""
+		}
+
+		return c, nil
+	}
+
+	if prev.Entries != nil {
+		c.Entries = prev.Entries
+	}
+
+	if prev.TemplateHash != templateHash {
+		for k := range c.Outputs {
+			c.Outputs[k] = ""
+		}
+	}
+
+	return c, nil
+}
+
+// Entry returns the cached entry for np if its source hash matches and the
+// images it depends on haven't changed.
+func (c *Cache) Entry(np string, sourceHash string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.visited[np] = true
+
+	e, ok := c.Entries[np]
+	if !ok || e.SourceHash != sourceHash {
+		return Entry{}, false
+	}
+
+	for p, stamp := range e.Images {
+		if Stamp(p) != stamp {
+			return Entry{}, false
+		}
+	}
+
+	return e, true
+}
+
+// SetEntry stores e for np.
+func (c *Cache) SetEntry(np string, e Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.visited[np] = true
+	c.Entries[np] = e
+}
+
+// Fresh records that outPath is produced by this build from input hashed to
+// hash, and reports whether the previous build already wrote it from the same
+// input, in which case it doesn't need to be written again.
+func (c *Cache) Fresh(outPath string, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.written[outPath] = hash
+
+	if c.Outputs[outPath] != hash {
+		return false
+	}
+
+	_, err := os.Stat(outPath)
+
+	return err == nil
+}
+
+// Keep records that outPath, written by the previous build, is still part of
+// this build even though it wasn't regenerated.
+func (c *Cache) Keep(outPath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hash, ok := c.Outputs[outPath]; ok {
+		c.written[outPath] = hash
+	}
+}
+
+// Prune removes outputs of the previous build that weren't produced by this
+// one, e.g. pages of deleted sources.
+func (c *Cache) Prune() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var removeErrors []error
+
+	for p := range c.Outputs {
+		if _, ok := c.written[p]; ok {
+			continue
+		}
+
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			removeErrors = append(removeErrors, fmt.Errorf("os.Remove %s: %w", p, err))
+		}
+	}
+
+	return errors.Join(removeErrors...)
+}
+
+// Save writes the cache to disk, replacing the previous outputs with the
+// outputs of this build and dropping the entries of sources it didn't visit,
+// e.g. deleted ones.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Outputs = c.written
+
+	for np := range c.Entries {
+		if !c.visited[np] {
+			delete(c.Entries, np)
+		}
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("os.MkdirAll %s: %w", c.dir, err)
+	}
+
+	// write to a temporary file first so an interrupted build can't leave a
+	// truncated cache behind
+	tmp, err := os.CreateTemp(c.dir, filename+".*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp %s: %w", c.dir, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(c); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode cache: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, filename))
+}
@@ -0,0 +1,57 @@
+package callout
+
+import (
+	"github.com/yuin/goldmark/ast"
+)
+
+// Kind is the kind of callout AST nodes.
+var Kind = ast.NewNodeKind("Callout")
+
+// KindTitle is the kind of callout title AST nodes.
+var KindTitle = ast.NewNodeKind("CalloutTitle")
+
+// Node is a callout. Its first child is its Title, followed by the blocks of
+// its body.
+type Node struct {
+	ast.BaseBlock
+
+	// Type of the callout, lowercased, e.g. note or warning
+	CalloutType string
+
+	// Whether the callout can be folded
+	Foldable bool
+
+	// Whether a foldable callout is open by default
+	Open bool
+}
+
+var _ ast.Node = (*Node)(nil)
+
+// Kind reports the kind of this node.
+func (n *Node) Kind() ast.NodeKind {
+	return Kind
+}
+
+// Dump dumps the Node to stdout.
+func (n *Node) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{
+		"CalloutType": n.CalloutType,
+	}, nil)
+}
+
+// Title is the title of a callout, holding inline nodes.
+type Title struct {
+	ast.BaseBlock
+}
+
+var _ ast.Node = (*Title)(nil)
+
+// Kind reports the kind of this node.
+func (n *Title) Kind() ast.NodeKind {
+	return KindTitle
+}
+
+// Dump dumps the Node to stdout.
+func (n *Title) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, nil, nil)
+}
@@ -0,0 +1,12 @@
+// Package callout turns blockquotes starting with [!type] into callouts, as
+// in Obsidian:
+//
+//	> [!warning] Custom title
+//	> Body of the callout.
+//
+// A + or - after the type makes the callout foldable, open or closed by
+// default:
+//
+//	> [!tip]- Click to expand
+//	> Hidden until expanded.
+package callout
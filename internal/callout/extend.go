@@ -0,0 +1,28 @@
+package callout
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Extender extends a goldmark Markdown object to render blockquotes starting
+// with [!type] as callouts.
+type Extender struct{}
+
+// Extend adds the callout Transformer and Renderer to the provided Markdown
+// parser/renderer.
+func (e *Extender) Extend(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&Transformer{}, 100),
+		),
+	)
+
+	md.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&Renderer{}, 100),
+		),
+	)
+}
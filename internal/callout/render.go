@@ -0,0 +1,77 @@
+package callout
+
+import (
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Renderer renders callouts as HTML: foldable callouts as <details>, others
+// as <div>.
+type Renderer struct{}
+
+var _ renderer.NodeRenderer = (*Renderer)(nil)
+
+// RegisterFuncs registers callout rendering functions with the provided
+// goldmark registerer.
+func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(Kind, r.renderCallout)
+	reg.Register(KindTitle, r.renderTitle)
+}
+
+func (r *Renderer) renderCallout(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Node)
+
+	if !entering {
+		_, _ = w.WriteString("</div>\n")
+
+		if n.Foldable {
+			_, _ = w.WriteString("</details>\n")
+		} else {
+			_, _ = w.WriteString("</div>\n")
+		}
+
+		return ast.WalkContinue, nil
+	}
+
+	tag := "div"
+	if n.Foldable {
+		tag = "details"
+	}
+
+	_, _ = w.WriteString("<" + tag + ` class="callout callout-`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.CalloutType)))
+	_, _ = w.WriteString(`"`)
+
+	if n.Open {
+		_, _ = w.WriteString(" open")
+	}
+
+	_, _ = w.WriteString(">\n")
+
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderTitle(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	foldable := node.Parent().(*Node).Foldable
+
+	if entering {
+		if foldable {
+			_, _ = w.WriteString(`<summary class="callout-title">`)
+		} else {
+			_, _ = w.WriteString(`<p class="callout-title">`)
+		}
+
+		return ast.WalkContinue, nil
+	}
+
+	if foldable {
+		_, _ = w.WriteString("</summary>\n")
+	} else {
+		_, _ = w.WriteString("</p>\n")
+	}
+
+	_, _ = w.WriteString(`<div class="callout-content">` + "\n")
+
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,135 @@
+package callout
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Transformer is a goldmark AST transformer that replaces blockquotes
+// starting with [!type] by callouts.
+type Transformer struct{}
+
+var _ parser.ASTTransformer = (*Transformer)(nil) // interface compliance
+
+// _marker matches the [!type] marker, with the fold sign, at the start of a
+// blockquote
+var _marker = regexp.MustCompile(`^\[!([A-Za-z0-9-]+)\]([+-]?)[ \t]*`)
+
+// Transform replaces the callouts of the document.
+func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	src := reader.Source()
+
+	quotes := []*ast.Blockquote{}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	for _, q := range quotes {
+		transform(q, src)
+	}
+}
+
+// transform replaces the blockquote q by a callout, if it starts with a
+// marker
+func transform(q *ast.Blockquote, src []byte) {
+	para, ok := q.FirstChild().(*ast.Paragraph)
+	if !ok || para.Lines().Len() == 0 {
+		return
+	}
+
+	first := para.Lines().At(0)
+
+	m := _marker.FindSubmatchIndex(first.Value(src))
+	if m == nil {
+		return
+	}
+
+	callout := &Node{
+		CalloutType: strings.ToLower(string(first.Value(src)[m[2]:m[3]])),
+		Foldable:    m[5] > m[4],
+		Open:        string(first.Value(src)[m[4]:m[5]]) == "+",
+	}
+
+	// Move the inlines of the first line, past the marker, to the title
+	title := &Title{}
+	markerEnd := first.Start + m[1]
+
+	for c := para.FirstChild(); c != nil; {
+		next := c.NextSibling()
+
+		start, ok := startOf(c)
+		if ok && start >= first.Stop {
+			break
+		}
+
+		if txt, ok := c.(*ast.Text); ok && txt.Segment.Start < markerEnd {
+			if txt.Segment.Stop <= markerEnd {
+				para.RemoveChild(para, c)
+				c = next
+
+				continue
+			}
+
+			txt.Segment = txt.Segment.WithStart(markerEnd)
+		}
+
+		para.RemoveChild(para, c)
+		title.AppendChild(title, c)
+		c = next
+	}
+
+	if txt, ok := title.LastChild().(*ast.Text); ok {
+		txt.SetSoftLineBreak(false)
+		txt.SetHardLineBreak(false)
+	}
+
+	if !title.HasChildren() {
+		title.AppendChild(title, ast.NewString([]byte(defaultTitle(callout.CalloutType))))
+	}
+
+	// The paragraph is left empty if the body doesn't start right after
+	// the title
+	if !para.HasChildren() {
+		q.RemoveChild(q, para)
+	}
+
+	callout.AppendChild(callout, title)
+
+	for c := q.FirstChild(); c != nil; {
+		next := c.NextSibling()
+		callout.AppendChild(callout, c)
+		c = next
+	}
+
+	q.Parent().ReplaceChild(q.Parent(), q, callout)
+}
+
+// startOf returns the start of the source of the inline n, if known
+func startOf(n ast.Node) (int, bool) {
+	if txt, ok := n.(*ast.Text); ok {
+		return txt.Segment.Start, true
+	}
+
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if start, ok := startOf(c); ok {
+			return start, true
+		}
+	}
+
+	return 0, false
+}
+
+// defaultTitle returns the title of callouts without one, their capitalised
+// type
+func defaultTitle(t string) string {
+	return strings.ToUpper(t[:1]) + t[1:]
+}
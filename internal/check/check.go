@@ -0,0 +1,252 @@
+// Package check finds broken links in sources
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/assetpath"
+	"github.com/mstcl/pher/v3/internal/customanchor"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/source"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+// Kinds of Problem
+const (
+	UnresolvedWikilink = "unresolved-wikilink"
+	DanglingFragment   = "dangling-fragment"
+	MissingAsset       = "missing-asset"
+	DraftLink          = "draft-link"
+	UnpublishedLink    = "unpublished-link"
+	AmbiguousWikilink  = "ambiguous-wikilink"
+)
+
+// Problem is a broken link.
+//
+// * File: source file the link is in, relative to the working directory
+//
+// * Line: line of the link, 0 if unknown
+//
+// * Candidates: sources an ambiguous wikilink may point to, relative to the
+// input directory, starting with the one it resolved to
+type Problem struct {
+	File       string   `json:"file"`
+	Kind       string   `json:"kind"`
+	Target     string   `json:"target"`
+	Candidates []string `json:"candidates,omitempty"`
+	Line       int      `json:"line"`
+}
+
+// Message describes the problem
+func (p Problem) Message() string {
+	switch p.Kind {
+	case UnresolvedWikilink:
+		return fmt.Sprintf("unresolved wikilink to %q", p.Target)
+	case DanglingFragment:
+		return fmt.Sprintf("no heading for fragment %q", p.Target)
+	case MissingAsset:
+		return fmt.Sprintf("missing asset %q", p.Target)
+	case DraftLink:
+		return fmt.Sprintf("link to draft %q", p.Target)
+	case UnpublishedLink:
+		return fmt.Sprintf("link to unpublished page %q", p.Target)
+	case AmbiguousWikilink:
+		return fmt.Sprintf("ambiguous wikilink to %q, resolved to the first of: %s", p.Target, strings.Join(p.Candidates, ", "))
+	}
+
+	return p.Kind
+}
+
+// Broken reports whether the link is broken, failing checks and strict
+// builds. Ambiguous wikilinks still resolve, so they are only warnings.
+func (p Problem) Broken() bool {
+	return p.Kind != AmbiguousWikilink
+}
+
+// CountBroken returns the number of problems that are broken links
+func CountBroken(problems []Problem) int {
+	n := 0
+
+	for _, p := range problems {
+		if p.Broken() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// String formats the problem as file:line: message
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message())
+}
+
+// Run checks the links of all published, non-draft sources. Must be called
+// after extractExtras() and populateNodePathLinks().
+func Run(s *state.State) []Problem {
+	problems := []Problem{}
+
+	for _, np := range s.NodePaths {
+		entry := s.NodeMap[np]
+		if entry.Metadata.Hidden() {
+			continue
+		}
+
+		dir := filepath.Dir(np.String())
+		file := displayPath(np.String())
+
+		for _, ref := range entry.Links.Refs {
+			p := Problem{File: file, Line: ref.Line}
+
+			switch ref.Kind {
+			case source.RefImage:
+				if isRemote(ref.Target) {
+					continue
+				}
+
+				if !assetExists(s.InputDir, dir, ref.Target) {
+					p.Kind, p.Target = MissingAsset, ref.Target
+					problems = append(problems, p)
+				}
+			case source.RefLink:
+				if !slices.Contains(entry.Links.Headings, ref.Fragment) {
+					p.Kind, p.Target = DanglingFragment, "#"+ref.Fragment
+					problems = append(problems, p)
+				}
+			case source.RefWikilink:
+				if kind, target, candidates, ok := checkWikilink(s, np, ref); !ok {
+					p.Kind, p.Target, p.Candidates = kind, target, candidates
+					problems = append(problems, p)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File == problems[j].File {
+			return problems[i].Line < problems[j].Line
+		}
+
+		return problems[i].File < problems[j].File
+	})
+
+	return problems
+}
+
+// checkWikilink resolves a wikilink of np the same way as extractExtras(),
+// returning the kind of problem, the offending target and the candidates of
+// ambiguous wikilinks if it is broken
+func checkWikilink(s *state.State, np nodepath.NodePath, ref source.Ref) (string, string, []string, bool) {
+	// fragment of the source itself
+	if len(ref.Target) == 0 {
+		if _, ok := customanchor.HeadingID(s.NodeMap[np].Links.Headings, ref.Fragment); len(ref.Fragment) > 0 && !ok {
+			return DanglingFragment, "#" + ref.Fragment, nil, false
+		}
+
+		return "", "", nil, true
+	}
+
+	// links with extensions are assets, relative to the source
+	if len(filepath.Ext(ref.Target)) > 0 {
+		if !assetExists(s.InputDir, filepath.Dir(np.String()), ref.Target) {
+			return MissingAsset, ref.Target, nil, false
+		}
+
+		return "", "", nil, true
+	}
+
+	linked, candidates := s.Resolver.Resolve(np, ref.Target)
+	if len(linked) == 0 {
+		return UnresolvedWikilink, ref.Target, nil, false
+	}
+
+	entry := s.NodeMap[linked]
+	if entry.Metadata.Draft {
+		return DraftLink, ref.Target, nil, false
+	}
+
+	if entry.Metadata.Unpublished {
+		return UnpublishedLink, ref.Target, nil, false
+	}
+
+	// fragments also take the text of headings, like ResolveWikilink()
+	if _, ok := customanchor.HeadingID(entry.Links.Headings, ref.Fragment); len(ref.Fragment) > 0 && !ok {
+		return DanglingFragment, ref.Target + "#" + ref.Fragment, nil, false
+	}
+
+	if len(candidates) > 0 {
+		// the resolved source comes first
+		rels := []string{s.Resolver.Rel(linked)}
+		for _, c := range candidates {
+			if c != linked {
+				rels = append(rels, s.Resolver.Rel(c))
+			}
+		}
+
+		return AmbiguousWikilink, ref.Target, rels, false
+	}
+
+	return "", "", nil, true
+}
+
+// WriteText writes the problems one per line
+func WriteText(w io.Writer, problems []Problem) error {
+	for _, p := range problems {
+		if _, err := fmt.Fprintln(w, p.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the problems as a JSON array
+func WriteJSON(w io.Writer, problems []Problem) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	return e.Encode(problems)
+}
+
+// isRemote reports whether dest links outside the site
+func isRemote(dest string) bool {
+	u, err := url.Parse(dest)
+
+	return err == nil && (len(u.Scheme) > 0 || len(u.Host) > 0)
+}
+
+// assetExists reports whether the asset dest, linked from a source in dir,
+// exists. It is resolved the same way as extractExtras() collects assets.
+func assetExists(inputDir string, dir string, dest string) bool {
+	p, err := assetpath.Resolve(inputDir, dir, dest)
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(p.String())
+
+	return err == nil
+}
+
+// displayPath returns p relative to the working directory if it is inside it
+func displayPath(p string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return p
+	}
+
+	rel, err := filepath.Rel(wd, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return p
+	}
+
+	return rel
+}
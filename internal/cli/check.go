@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/mstcl/pher/v3/internal/check"
+	"github.com/mstcl/pher/v3/internal/config"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+// runCheck reports broken links and missing assets in the input directory
+// without rendering anything. Paths in the state are expected to be
+// sanitized.
+func runCheck(s *state.State) error {
+	var err error
+
+	if s.CheckFormat != "text" && s.CheckFormat != "json" {
+		return fmt.Errorf("unknown format: %s", s.CheckFormat)
+	}
+
+	// parse configuration
+	s.Config, err = config.Read(s.ConfigFile)
+	if err != nil {
+		return err
+	}
+	Logger.Debug("parsed configuration", slog.Any("config", s.Config))
+
+	// get source files from input directory
+	s.NodePaths, err = getNodePaths(s.InputDir)
+	if err != nil {
+		return err
+	}
+	Logger.Debug("found source files", slog.Any("paths", s.NodePaths))
+
+	if err := extractExtras(s); err != nil {
+		return err
+	}
+
+	if err := populateNodePathLinks(s); err != nil {
+		return err
+	}
+
+	problems := check.Run(s)
+
+	if s.CheckFormat == "json" {
+		err = check.WriteJSON(os.Stdout, problems)
+	} else {
+		err = check.WriteText(os.Stdout, problems)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if n := check.CountBroken(problems); n > 0 {
+		return fmt.Errorf("found %d broken links", n)
+	}
+
+	Logger.Info("no broken links found", slog.Int("number of files", len(s.NodePaths)))
+
+	return nil
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"time"
 
+	"github.com/mstcl/pher/v3/internal/check"
 	"github.com/mstcl/pher/v3/internal/config"
 	"github.com/mstcl/pher/v3/internal/state"
 )
@@ -20,16 +22,16 @@ const (
 	relTemplateDir     = "web/template"
 	relStaticDir       = "web/static"
 	relStaticOutputDir = "static"
+	relLayoutsDir      = "layouts"
 )
 
 func Handler() error {
-	var err error
-
-	start := time.Now() // start execution timer
-
 	s := state.Init() // this is our app state
 
-	parseFlags(&s) // parse all our CLI flags here (onto the state)
+	// parse all our CLI flags here (onto the state)
+	if err := parseFlags(&s, os.Args[1:]); err != nil {
+		return err
+	}
 
 	if s.Debug {
 		LogLevelVar.Set(slog.LevelDebug)
@@ -47,12 +49,18 @@ func Handler() error {
 	)
 
 	Logger.Debug("parsed flags",
+		slog.String("command", s.Command),
 		slog.String("inDir", s.InputDir),
 		slog.String("outDir", s.OutputDir),
 		slog.String("configFile", s.ConfigFile),
+		slog.String("since", s.Since),
 		slog.Bool("version", s.ShowVersion),
 		slog.Bool("dryRun", s.DryRun),
+		slog.Bool("strict", s.Strict),
 		slog.Bool("debug", s.Debug),
+		slog.Bool("buildFuture", s.BuildFuture),
+		slog.Bool("buildExpired", s.BuildExpired),
+		slog.Time("now", s.Clock()),
 	)
 
 	// show version and exit if that's the case
@@ -61,8 +69,33 @@ func Handler() error {
 		return nil
 	}
 
-	// sanitize paths
-	sanitize(&s)
+	switch s.Command {
+	case cmdBuild:
+		// sanitize paths
+		if err := sanitize(&s); err != nil {
+			return err
+		}
+
+		return build(&s)
+	case cmdServe:
+		return serve(&s)
+	case cmdCheck:
+		if err := sanitize(&s); err != nil {
+			return err
+		}
+
+		return runCheck(&s)
+	default:
+		return fmt.Errorf("unknown command: %s", s.Command)
+	}
+}
+
+// build runs a single build of the input directory into the output directory.
+// Paths in the state are expected to be sanitized.
+func build(s *state.State) error {
+	var err error
+
+	start := time.Now() // start execution timer
 
 	// create output directory
 	if err := createDir(s.OutputDir); err != nil {
@@ -77,8 +110,21 @@ func Handler() error {
 	}
 	Logger.Debug("parsed configuration", slog.Any("config", s.Config))
 
+	// load the build cache, in which case the output directory is pruned
+	// after rendering instead of cleaned beforehand
+	if s.Config.Cache {
+		if err := loadCache(s); err != nil {
+			return err
+		}
+		Logger.Debug("loaded build cache", slog.Int("entries", len(s.Cache.Entries)))
+	}
+
 	// clean output directory
-	if !s.DryRun {
+	if len(s.Since) > 0 {
+		Logger.Debug("partial build — skipped cleaning output directory")
+	} else if s.Cache != nil {
+		Logger.Debug("incremental build — skipped cleaning output directory")
+	} else if !s.DryRun {
 		exceptions := []string{relStaticOutputDir}
 
 		if err := cleanOutputDir(s.OutputDir, exceptions); err != nil {
@@ -90,7 +136,9 @@ func Handler() error {
 	}
 
 	// initiate templates
-	initTemplates(&s)
+	if err := initTemplates(s); err != nil {
+		return err
+	}
 	Logger.Debug("loaded and initialized templates")
 
 	// get source files from input directory
@@ -100,25 +148,66 @@ func Handler() error {
 	}
 	Logger.Debug("found source files", slog.Any("paths", s.NodePaths))
 
+	// read dates of sources lacking them in their frontmatter
+	if err := loadDates(s); err != nil {
+		return err
+	}
+	Logger.Debug("loaded source dates", slog.String("dates", s.Config.Dates), slog.Int("sources", len(s.FileDates)))
+
 	// TODO: refactor
 	// update the state with various metadata
-	if err := extractExtras(&s); err != nil {
+	if err := extractExtras(s); err != nil {
 		return err
 	}
 	Logger.Info("extracted metadata and file relations")
 
+	// inline the content of embedded pages
+	transcludeEmbeds(s)
+	Logger.Debug("transcluded embedded pages", slog.Any("embeds", s.EmbedsMap))
+
 	// TODO: refactor
 	// update the state with file listings, like backlinks and similar entries
-	if err := populateNodePathLinks(&s); err != nil {
+	if err := populateNodePathLinks(s); err != nil {
 		return err
 	}
 	Logger.Info("created file index")
 
+	// report broken links, failing on them if strict
+	if problems := check.Run(s); len(problems) > 0 {
+		for _, p := range problems {
+			Logger.Warn(p.Message(), slog.String("file", p.File), slog.Int("line", p.Line))
+		}
+
+		if n := check.CountBroken(problems); s.Strict && n > 0 {
+			return fmt.Errorf("found %d broken links", n)
+		}
+	}
+
+	// narrow down the pages to render to those affected by changes
+	if len(s.Since) > 0 {
+		if err := planPartialBuild(s); err != nil {
+			return err
+		}
+		Logger.Info("planned partial build", slog.String("since", s.Since), slog.Int("pages", len(s.RenderOnly)))
+	}
+
 	// do the rest of our tasks concurrently
-	if err := runConcurrentJobs(context.Background(), &s); err != nil {
+	if err := runConcurrentJobs(context.Background(), s); err != nil {
 		return err
 	}
 
+	// remove outputs of deleted sources and persist the cache
+	if s.Cache != nil && !s.DryRun {
+		if err := s.Cache.Prune(); err != nil {
+			return err
+		}
+
+		if err := s.Cache.Save(); err != nil {
+			return err
+		}
+		Logger.Info("saved build cache")
+	}
+
 	end := time.Since(start)
 	Logger.Info(
 		"completed",
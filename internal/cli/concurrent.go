@@ -2,33 +2,70 @@ package cli
 
 import (
 	"context"
+	"path/filepath"
 
 	"github.com/mstcl/pher/v3/internal/feed"
+	"github.com/mstcl/pher/v3/internal/graph"
+	"github.com/mstcl/pher/v3/internal/redirect"
 	"github.com/mstcl/pher/v3/internal/render"
+	"github.com/mstcl/pher/v3/internal/search"
+	"github.com/mstcl/pher/v3/internal/sitemap"
 	"github.com/mstcl/pher/v3/internal/state"
 	"golang.org/x/sync/errgroup"
 )
 
-// runConcurrentJobs executes three jobs of the rest of the program concurrently
+// runConcurrentJobs executes the rest of the program concurrently
 // as they are independent of each other:
-//  1. Create the atom feed
-//  2. Copy assets to the output directory
-//  3. Copy static files to the output directory
-//  4. Render all source files to HTML to the output directory
+//  1. Create the feeds, including the per-tag and per-nodegroup ones
+//  2. Create the sitemap and robots.txt
+//  3. Copy assets to the output directory
+//  4. Copy static files to the output directory
+//  5. Write the search index to the output directory
+//  6. Render all source files to HTML to the output directory
+//  7. Render the redirects of aliases, and write them for webservers
+//  8. Write the link graph to the output directory
 func runConcurrentJobs(ctx context.Context, s *state.State) error {
-	// construct and render atom feeds
+	// construct and render feeds
 	constructFeedGroup, _ := errgroup.WithContext(ctx)
 	constructFeedGroup.Go(func() error {
-		atom, err := feed.Construct(s)
+		f, err := feed.Construct(s)
 		if err != nil {
 			return err
 		}
 
-		return feed.Write(s, atom)
+		if err := feed.Write(s, f); err != nil {
+			return err
+		}
+
+		return writeSectionFeeds(s)
+	},
+	)
+
+	Logger.Info("created feeds")
+
+	// construct and write the sitemap and robots.txt
+	sitemapGroup, _ := errgroup.WithContext(ctx)
+	sitemapGroup.Go(func() error {
+		if s.Config.Sitemap && len(s.Config.Url) > 0 {
+			urlset, err := sitemap.Construct(s)
+			if err != nil {
+				return err
+			}
+
+			if err := sitemap.Write(s, urlset); err != nil {
+				return err
+			}
+		}
+
+		if s.Config.Robots.Enable {
+			return sitemap.WriteRobots(s)
+		}
+
+		return nil
 	},
 	)
 
-	Logger.Info("created atom feed")
+	Logger.Info("created sitemap")
 
 	// copy asset dirs/files over to output directory
 	copyUserAssetsGroup, _ := errgroup.WithContext(ctx)
@@ -55,6 +92,16 @@ func runConcurrentJobs(ctx context.Context, s *state.State) error {
 	)
 	Logger.Info("copied static files")
 
+	// build and write the search index
+	searchIndexGroup, _ := errgroup.WithContext(ctx)
+	if s.Config.Search.Enable {
+		searchIndexGroup.Go(func() error {
+			return search.Write(s, search.Construct(s))
+		})
+
+		Logger.Info("created search index")
+	}
+
 	// render all markdown files
 	renderGroup, _ := errgroup.WithContext(ctx)
 	renderGroup.Go(func() error {
@@ -62,11 +109,48 @@ func runConcurrentJobs(ctx context.Context, s *state.State) error {
 	})
 	Logger.Info("templated all source files")
 
+	// render redirect stubs and write redirect files
+	redirectGroup, _ := errgroup.WithContext(ctx)
+	redirectGroup.Go(func() error {
+		redirects := redirect.Construct(s)
+
+		if err := render.RenderRedirects(s, redirects); err != nil {
+			return err
+		}
+
+		if s.Config.Redirects.Netlify {
+			if err := redirect.WriteNetlify(s, redirects); err != nil {
+				return err
+			}
+		}
+
+		if s.Config.Redirects.Nginx {
+			return redirect.WriteNginx(s, redirects)
+		}
+
+		return nil
+	})
+	Logger.Info("created redirects")
+
+	// construct and write the link graph
+	graphGroup, _ := errgroup.WithContext(ctx)
+	if s.Config.Graph.Enable || s.Config.Graph.Page {
+		graphGroup.Go(func() error {
+			return graph.Write(s, graph.Construct(s))
+		})
+
+		Logger.Info("created graph")
+	}
+
 	// wait for all goroutines to finish
 	if err := constructFeedGroup.Wait(); err != nil {
 		return err
 	}
 
+	if err := sitemapGroup.Wait(); err != nil {
+		return err
+	}
+
 	if err := copyUserAssetsGroup.Wait(); err != nil {
 		return err
 	}
@@ -75,9 +159,63 @@ func runConcurrentJobs(ctx context.Context, s *state.State) error {
 		return err
 	}
 
+	if err := searchIndexGroup.Wait(); err != nil {
+		return err
+	}
+
 	if err := renderGroup.Wait(); err != nil {
 		return err
 	}
 
+	if err := redirectGroup.Wait(); err != nil {
+		return err
+	}
+
+	if err := graphGroup.Wait(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeSectionFeeds writes the feeds of each tag if enabled, and of each
+// nodegroup whose index opts in, in the primary feed format
+func writeSectionFeeds(s *state.State) error {
+	if len(s.Config.Feeds) == 0 {
+		return nil
+	}
+
+	primary := s.Config.Feeds[0]
+
+	if s.Config.TagFeeds {
+		for _, t := range s.NodeTags {
+			f, err := feed.ConstructTag(s, t)
+			if err != nil {
+				return err
+			}
+
+			if err := feed.WriteTo(s, f, primary.Format, feed.TagFilename(s, t.Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for np, entry := range s.NodeMap {
+		// the root nodegroup's feed is the global one
+		if np.Base() != "index" || !entry.Metadata.Feed || entry.Metadata.Hidden() ||
+			filepath.Dir(np.String()) == s.InputDir {
+			continue
+		}
+
+		f, err := feed.ConstructNodegroup(s, np)
+		if err != nil {
+			return err
+		}
+
+		if err := feed.WriteTo(s, f, primary.Format, feed.NodegroupFilename(s, np)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/git"
+	"github.com/mstcl/pher/v3/internal/metadata"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+// loadDates sets s.FileDates from the source of dates in the configuration:
+// the first and last commits touching each source in the history of HEAD, or
+// the modification time of each source. The history is only read again if
+// HEAD moved since the previous build. A modification time only says when a
+// source was last updated, and isn't used for index pages, which change
+// with their nodegroup.
+//
+// Must be called after getNodePaths().
+func loadDates(s *state.State) error {
+	switch s.Config.Dates {
+	case "git":
+		repo, err := git.Open(s.InputDir)
+		if err != nil {
+			return err
+		}
+		defer repo.Close()
+
+		head, err := repo.Resolve("HEAD")
+		if err != nil {
+			return err
+		}
+
+		if s.GitHistory == nil || s.GitHead != head {
+			s.GitHistory, err = repo.History(head)
+			if err != nil {
+				return err
+			}
+
+			s.GitHead = head
+
+			Logger.Debug("read git history", slog.String("commit", head.String()), slog.Int("files", len(s.GitHistory)))
+		}
+
+		history := s.GitHistory
+
+		for _, np := range s.NodePaths {
+			rel, err := filepath.Rel(repo.Root, np.String())
+			if err != nil {
+				continue
+			}
+
+			if d, ok := history[filepath.ToSlash(rel)]; ok {
+				s.FileDates[np] = d
+			}
+		}
+	case "mtime":
+		for _, np := range s.NodePaths {
+			if np.Base() == "index" {
+				continue
+			}
+
+			info, err := os.Stat(np.String())
+			if err != nil {
+				return fmt.Errorf("os.Stat %s: %w", np, err)
+			}
+
+			s.FileDates[np] = git.FileDates{Updated: info.ModTime()}
+		}
+	}
+
+	return nil
+}
+
+// fillDates sets the date and updated date of md from s.FileDates if its
+// frontmatter has none and they are known. The updated date is only set if
+// it's later than the date.
+func fillDates(s *state.State, np nodepath.NodePath, md *metadata.Metadata) {
+	d, ok := s.FileDates[np]
+	if !ok {
+		return
+	}
+
+	if len(md.Date) == 0 && !d.Created.IsZero() {
+		md.Date = d.Created.In(s.Config.Location).Format(time.RFC3339)
+	}
+
+	if len(md.DateUpdated) > 0 || d.Updated.IsZero() {
+		return
+	}
+
+	if len(md.Date) > 0 {
+		// Invalid dates are reported when rendering
+		date, err := convert.ParseDate(md.Date, s.Config.Location)
+		if err != nil || !d.Updated.After(date) {
+			return
+		}
+	}
+
+	md.DateUpdated = d.Updated.In(s.Config.Location).Format(time.RFC3339)
+}
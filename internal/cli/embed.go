@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/customanchor"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/state"
+	"github.com/mstcl/pher/v3/internal/wikilink"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	// headingPattern matches opening heading tags, capturing the level
+	headingPattern = regexp.MustCompile(`<h([1-6])[^>]*>`)
+
+	// urlAttrPattern matches attributes holding links
+	urlAttrPattern = regexp.MustCompile(`\b(href|src|srcset)="([^"]*)"`)
+)
+
+// transcludeEmbeds replaces page embeds (![[page]] and ![[page#heading]])
+// in the body of every node with the content of the embedded page or
+// section, and records the embedded nodepaths in EmbedsMap. Must be called
+// after extractExtras() and before populateNodePathLinks(), which copies
+// bodies into log listings.
+func transcludeEmbeds(s *state.State) {
+	// bodies: bodies before transclusion (key: nodepath)
+	bodies := make(map[nodepath.NodePath][]byte, len(s.NodeMap))
+	for np, entry := range s.NodeMap {
+		bodies[np] = entry.Body
+	}
+
+	for np, body := range bodies {
+		if !bytes.Contains(body, []byte("<!--pher:embed ")) {
+			continue
+		}
+
+		deps := make(map[nodepath.NodePath]bool)
+
+		entry := s.NodeMap[np]
+		entry.Body = expandEmbeds(s, bodies, np, body, []nodepath.NodePath{np}, deps)
+		s.NodeMap[np] = entry
+
+		for dep := range deps {
+			s.EmbedsMap[np] = append(s.EmbedsMap[np], dep)
+		}
+
+		slices.Sort(s.EmbedsMap[np])
+	}
+}
+
+// expandEmbeds replaces the embeds in body, the body of np, recursively.
+// Links in the result are relative to np. stack holds the nodepaths being
+// expanded to detect cycles, and deps collects all embedded nodepaths.
+func expandEmbeds(
+	s *state.State,
+	bodies map[nodepath.NodePath][]byte,
+	np nodepath.NodePath,
+	body []byte,
+	stack []nodepath.NodePath,
+	deps map[nodepath.NodePath]bool,
+) []byte {
+	return wikilink.ReplaceEmbeds(body, func(target string, fragment string, inline bool) []byte {
+		child := Logger.With(
+			slog.Any("nodepath", np),
+			slog.String("target", target),
+			slog.String("context", "transcluding"),
+		)
+
+		ref, _ := s.Resolver.Resolve(np, target)
+
+		// link to the embedded page, used when it can't be embedded,
+		// escaped like the links of the wikilink renderer
+		href := target + ".html"
+		if len(ref) > 0 {
+			href = s.Resolver.Href(np, ref)
+		}
+
+		// fragments are heading IDs, or heading text as in
+		// [[page#Heading Text]]
+		id, found := customanchor.HeadingID(s.NodeMap[ref].Links.Headings, fragment)
+		if len(fragment) > 0 {
+			href += "#" + id
+		}
+
+		href = string(util.URLEscape([]byte(href), true))
+
+		link := fmt.Appendf(nil,
+			`<a class="wikilink" href="%s">%s</a>`,
+			html.EscapeString(href), html.EscapeString(target),
+		)
+
+		// unresolved embeds and embeds of drafts are reported by check.
+		// Inline embeds are links, as a paragraph can't hold the page.
+		content, ok := bodies[ref]
+		if !ok || s.NodeMap[ref].Metadata.Hidden() || inline {
+			return link
+		}
+
+		if slices.Contains(stack, ref) {
+			child.Warn("embed cycle, rendering as link", slog.Any("cycle", append(stack, ref)))
+
+			return link
+		}
+
+		if len(stack) > s.Config.EmbedDepth {
+			child.Warn("embeds nested too deep, rendering as link", slog.Int("embedDepth", s.Config.EmbedDepth))
+
+			return link
+		}
+
+		if len(fragment) > 0 {
+			sec := section(content, id)
+			if !found || sec == nil {
+				return link
+			}
+
+			content = sec
+		}
+
+		deps[ref] = true
+
+		content = expandEmbeds(s, bodies, ref, content, append(stack, ref), deps)
+		content = rebaseLinks(content, path.Dir(href))
+
+		child.Debug("embedded page")
+
+		title := convert.Title(s.NodeMap[ref].Metadata.Title, ref.Base())
+
+		return fmt.Appendf(nil,
+			"<aside class=\"embed\">\n<p class=\"embed-source\"><a class=\"wikilink\" href=\"%s\">%s</a></p>\n%s</aside>\n",
+			html.EscapeString(href), html.EscapeString(title), content,
+		)
+	})
+}
+
+// section returns the html of the section of body under the heading with the
+// given id, up to the next heading of the same or a higher level, or nil if
+// there is no such heading
+func section(body []byte, id string) []byte {
+	attr := []byte(`id="` + html.EscapeString(id) + `"`)
+	locs := headingPattern.FindAllSubmatchIndex(body, -1)
+
+	for i, loc := range locs {
+		if !bytes.Contains(body[loc[0]:loc[1]], attr) {
+			continue
+		}
+
+		level := body[loc[2]]
+		end := len(body)
+
+		for _, next := range locs[i+1:] {
+			if body[next[2]] <= level {
+				end = next[0]
+
+				break
+			}
+		}
+
+		return body[loc[0]:end]
+	}
+
+	return nil
+}
+
+// rebaseLinks prefixes the relative links in html with prefix, so links of an
+// embedded page keep working from the embedding page
+func rebaseLinks(html []byte, prefix string) []byte {
+	if prefix == "." {
+		return html
+	}
+
+	return urlAttrPattern.ReplaceAllFunc(html, func(m []byte) []byte {
+		sub := urlAttrPattern.FindSubmatch(m)
+		attr, val := string(sub[1]), string(sub[2])
+
+		if attr == "srcset" {
+			candidates := strings.Split(val, ", ")
+			for i, c := range candidates {
+				candidates[i] = rebase(c, prefix)
+			}
+
+			val = strings.Join(candidates, ", ")
+		} else {
+			val = rebase(val, prefix)
+		}
+
+		return fmt.Appendf(nil, `%s="%s"`, attr, val)
+	})
+}
+
+// rebase prefixes u with prefix if u is a relative link to another file
+func rebase(u string, prefix string) string {
+	if len(u) == 0 || strings.HasPrefix(u, "#") || strings.HasPrefix(u, "/") {
+		return u
+	}
+
+	if parsed, err := url.Parse(u); err != nil || len(parsed.Scheme) > 0 {
+		return u
+	}
+
+	return path.Join(prefix, u)
+}
@@ -1,36 +1,70 @@
 package cli
 
 import (
-	"bytes"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/mstcl/pher/v3/internal/assetpath"
+	"github.com/mstcl/pher/v3/internal/cache"
 	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/imageproc"
+	"github.com/mstcl/pher/v3/internal/metadata"
 	"github.com/mstcl/pher/v3/internal/nodepath"
 	"github.com/mstcl/pher/v3/internal/nodepathlink"
+	"github.com/mstcl/pher/v3/internal/resolver"
 	"github.com/mstcl/pher/v3/internal/source"
 	"github.com/mstcl/pher/v3/internal/state"
 	"github.com/mstcl/pher/v3/internal/tag"
+	"github.com/mstcl/pher/v3/internal/toc"
 )
 
 // Process files to build up the entry data for all files, the tags data, and
 // the linked internal asset.
 // Exclusive calls to parse.* are made here.
-// Calls source.ExtractMetadata()
-// Calls source.ToHTML()
-// Calls source.ExtractLinks()
+// Calls processSource(), which calls source.ExtractMetadata(),
+// source.ToHTML() and source.ExtractLinks() on uncached sources
 // Further business logic to construct the backlinks, relatedlinks, asset map and tags slice
 func extractExtras(s *state.State) error {
-	// tagsCount: tags count (key: tag name)
-	tagsCount := make(map[string]int)
-
 	// tagsListing: tags listing - files with this tag (key: tag name)
 	tagsListing := make(map[string][]nodepathlink.NodePathLink)
 
+	// bodies: raw sources (key: nodepath)
+	bodies := make(map[nodepath.NodePath][]byte)
+
+	// mds: metadata of all sources (key: nodepath)
+	mds := make(map[nodepath.NodePath]metadata.Metadata)
+
+	// Read in all sources and their metadata first, so that wikilinks can
+	// be resolved against the whole input directory
+
+	for _, np := range s.NodePaths {
+		body, err := os.ReadFile(np.String())
+		if err != nil {
+			return err
+		}
+
+		md, err := processMetadata(s, np, body)
+		if err != nil {
+			return err
+		}
+
+		fillDates(s, np, md)
+
+		if err := unpublish(s, np, md); err != nil {
+			return err
+		}
+
+		bodies[np] = body
+		mds[np] = *md
+	}
+
+	s.Resolver = resolver.New(s.InputDir, mds)
+
+	Logger.Debug("indexed sources for wikilinks")
+
 	// First loop, can do most things
 	for _, np := range s.NodePaths {
 		child := Logger.With(
@@ -40,58 +74,47 @@ func extractExtras(s *state.State) error {
 
 		entry := s.NodeMap[np]
 
-		file, err := os.Open(np.String())
+		processed, err := processSource(s, np, bodies[np])
 		if err != nil {
 			return err
 		}
 
-		defer file.Close()
+		md := &processed.Metadata
+		links := &processed.Links
 
-		buf := new(bytes.Buffer)
-		if _, err := buf.ReadFrom(file); err != nil {
+		fillDates(s, np, md)
+
+		if err := unpublish(s, np, md); err != nil {
 			return err
 		}
 
-		child.Debug("read in file")
-
-		src := source.Source{
-			Body:          buf.Bytes(),
-			CodeHighlight: s.Config.CodeHighlight,
-			CodeTheme:     s.Config.CodeTheme,
+		for _, u := range processed.Unsupported {
+			child.Warn(
+				"unsupported or malformed math",
+				slog.String("command", u.Command),
+				slog.Int("line", u.Line),
+			)
 		}
 
-		md, err := src.ExtractMetadata()
-		if err != nil {
-			return err
+		if p := s.Config.TOC.For(md).Placement; p != "inline" && p != "aside" {
+			child.Warn("unknown toc placement, using inline", slog.String("placement", p))
 		}
 
-		child.Debug("extracted metadata", slog.Any("metadata", md))
-
-		src.TOC = md.TOC
+		// Don't proceed if file is draft or unpublished, but keep its
+		// metadata (and body, if converted) so it can be recognised as such
+		// later on
+		if md.Hidden() {
+			entry.Metadata = *md
+			entry.Body = processed.Body
+			entry.TOC = processed.TOC
+			entry.Links = processed.Links
+			s.NodeMap[np] = entry
 
-		// Don't proceed if file is draft
-		if md.Draft {
 			child.Debug("skipping: file is draft")
 
 			continue
 		}
 
-		// Extract and parse html body
-		rendered, err := src.ToHTML()
-		if err != nil {
-			return err
-		}
-
-		child.Debug("extracted html")
-
-		// Extract wiki backlinks (blinks) and image links (internalLinks)
-		links, err := src.ExtractLinks()
-		if err != nil {
-			return err
-		}
-
-		child.Debug("extracted links", slog.Any("links", links))
-
 		// Resolve basic vars
 		path := filepath.Dir(np.String())
 		base := np.Base()
@@ -105,42 +128,50 @@ func extractExtras(s *state.State) error {
 
 		// Update entry
 		entry.Metadata = *md
-		entry.Body = rendered.HTML
+		entry.Body = processed.Body
 		entry.Href = href
-		entry.ChromaCSS = rendered.ChromaCSS
+		entry.ChromaCSS = processed.ChromaCSS
+		entry.TOC = processed.TOC
+		entry.Links = processed.Links
 		s.NodeMap[np] = entry
 
 		// Update assets from internal links
 		for _, v := range links.InternalLinks {
 			// Absolutize image links
-			ref, err := filepath.Abs(filepath.Join(path, v))
+			ref, err := assetpath.Resolve(s.InputDir, path, v)
 			if err != nil {
-				return nil
+				return err
 			}
 
-			s.UserAssetMap[assetpath.AssetPath(ref)] = true
+			s.UserAssetMap[ref] = true
 		}
 
 		child.Debug("updated assets with internal links paths", slog.Any("assets", s.UserAssetMap))
 
 		// Update assets and wikilinks from backlinks
 		for _, v := range links.BackLinks {
-			// Reconstruct wikilink into full input path
-			ref, err := filepath.Abs(filepath.Join(path, v))
-			if err != nil {
-				return err
-			}
-
 			// Process links with extensions as external files
-			// like images/gifs
-			if len(filepath.Ext(ref)) > 0 {
-				s.UserAssetMap[assetpath.AssetPath(ref)] = true
+			// like images/gifs, relative to the source
+			if len(filepath.Ext(v)) > 0 {
+				ref, err := assetpath.Resolve(s.InputDir, path, v)
+				if err != nil {
+					return err
+				}
+
+				s.UserAssetMap[ref] = true
+
+				continue
 			}
 
-			ref += ".md"
+			// Don't create entries for unresolved wikilinks, these are
+			// reported by check
+			ref, _ := s.Resolver.Resolve(np, v)
+			if len(ref) == 0 {
+				continue
+			}
 
 			// Save backlinks
-			linkedEntry := s.NodeMap[nodepath.NodePath(ref)]
+			linkedEntry := s.NodeMap[ref]
 			linkedEntry.Backlinks = append(
 				linkedEntry.Backlinks,
 				nodepathlink.NodePathLink{
@@ -150,28 +181,26 @@ func extractExtras(s *state.State) error {
 					IsDir:       isDir,
 				},
 			)
-			s.NodeMap[nodepath.NodePath(ref)] = linkedEntry
+			s.NodeMap[ref] = linkedEntry
 		}
 
 		child.Debug("updated assets and wiklinks from backlinks")
 
-		// Grab tags count and tags listing
+		// Grab tags listing
 		// We process the final tags later - this is
 		// for the tags page
 		for _, v := range md.Tags {
-			tagsCount[v] += 1
-
 			tagsListing[v] = append(tagsListing[v], nodepathlink.NodePathLink{
 				Href:        href,
 				Title:       title,
 				Description: entry.Metadata.Description,
 				IsDir:       isDir,
+				Keys:        sortKeys(s, *md, filepath.Base(np.String())),
 			})
 		}
 
 		child.Debug(
 			"updated tags",
-			slog.Any("tagsCount", tagsCount),
 			slog.Any("tagsListing", tagsListing),
 		)
 	}
@@ -189,7 +218,7 @@ func extractExtras(s *state.State) error {
 		)
 
 		entry := s.NodeMap[np]
-		if entry.Metadata.Draft || len(entry.Metadata.Tags) == 0 {
+		if entry.Metadata.Hidden() || len(entry.Metadata.Tags) == 0 {
 			continue
 		}
 
@@ -230,23 +259,197 @@ func extractExtras(s *state.State) error {
 		s.NodeMap[np] = entry
 	}
 
-	// Transform maps of tags count and tags listing to give a sorted slice of tags.
-	// Used by render.RenderTags exclusively.
-	tags := []tag.Tag{}
-	keys := make([]string, 0, len(tagsCount))
+	// Transform the tags listing to give a sorted slice of tags, nested
+	// ones rolled up into their ancestors. Used by render.RenderTags
+	// exclusively.
+	tags := tag.Construct(tagsListing)
 
-	for k := range tagsCount {
-		keys = append(keys, k)
+	for _, t := range tags {
+		// Sort tag listings like directory listings, by the config
+		if err := nodepathlink.Sort(t.Links, s.Config.Sort); err != nil {
+			_ = nodepathlink.Sort(t.Links, "filename")
+		}
 	}
 
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		tags = append(tags, tag.Tag{Name: k, Count: tagsCount[k], Links: tagsListing[k]})
-	}
 	s.NodeTags = append(s.NodeTags, tags...)
 
 	Logger.Debug("extracted tags", slog.Any("tags", s.NodeTags))
 
 	return nil
 }
+
+// unpublish marks md as unpublished if it isn't published yet or has
+// expired, as of s.Clock, so that it's left out everywhere drafts are, and
+// from search. The cache keeps the metadata as written.
+func unpublish(s *state.State, np nodepath.NodePath, md *metadata.Metadata) error {
+	if md.Draft {
+		return nil
+	}
+
+	child := Logger.With(slog.Any("nodepath", np), slog.String("context", "scheduling"))
+	now := s.Clock()
+
+	publishDate, err := convert.ParseDate(md.PublishDate, s.Config.Location)
+	if err != nil {
+		return fmt.Errorf("%s: publishDate: %w", np, err)
+	}
+
+	expiryDate, err := convert.ParseDate(md.ExpiryDate, s.Config.Location)
+	if err != nil {
+		return fmt.Errorf("%s: expiryDate: %w", np, err)
+	}
+
+	// Invalid dates are reported when rendering
+	if publishDate.IsZero() {
+		publishDate, _ = convert.ParseDate(md.Date, s.Config.Location)
+	}
+
+	if publishDate.After(now) && !s.BuildFuture {
+		child.Debug("unpublished: publish date is in the future", slog.Time("date", publishDate))
+
+		md.Unpublished = true
+
+		return nil
+	}
+
+	if !expiryDate.IsZero() && !expiryDate.After(now) && !s.BuildExpired {
+		child.Debug("unpublished: expired", slog.Time("expiryDate", expiryDate))
+
+		md.Unpublished = true
+	}
+
+	return nil
+}
+
+// processMetadata extracts the metadata of a source file, reusing the cached
+// metadata if the source hasn't changed since the previous build.
+func processMetadata(s *state.State, np nodepath.NodePath, body []byte) (*metadata.Metadata, error) {
+	if s.Cache != nil {
+		if e, ok := s.Cache.Entry(np.String(), cache.Hash(body)); ok {
+			return &e.Metadata, nil
+		}
+	}
+
+	src := source.Source{Body: body}
+
+	return src.ExtractMetadata()
+}
+
+// processSource extracts the metadata, html body and links of a source file,
+// reusing the cached results if the source hasn't changed since the previous
+// build. Drafts only have their metadata extracted.
+func processSource(s *state.State, np nodepath.NodePath, body []byte) (*cache.Entry, error) {
+	child := Logger.With(
+		slog.Any("nodepath", np),
+		slog.String("context", "processing source"),
+	)
+
+	sourceHash := cache.Hash(body)
+
+	if s.Cache != nil {
+		if e, ok := s.Cache.Entry(np.String(), sourceHash); ok && resolvesSame(s, np, e.Resolved) {
+			child.Debug("reusing cached entry")
+
+			return &e, nil
+		}
+	}
+
+	res := s.Resolver.For(np)
+
+	src := source.Source{
+		Body:          body,
+		Dir:           filepath.Dir(np.String()),
+		Resolver:      res,
+		Images:        imagePipeline(s),
+		CodeHighlight: s.Config.CodeHighlight,
+		CodeTheme:     s.Config.CodeTheme,
+		Math:          s.Config.Math,
+	}
+
+	md, err := src.ExtractMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	child.Debug("extracted metadata", slog.Any("metadata", md))
+
+	e := &cache.Entry{SourceHash: sourceHash, Metadata: *md}
+
+	if !md.Draft {
+		src.TOC = tocTransformer(s, md)
+
+		// Extract and parse html body
+		rendered, err := src.ToHTML()
+		if err != nil {
+			return nil, err
+		}
+
+		child.Debug("extracted html")
+
+		// Extract wiki backlinks (blinks) and image links (internalLinks)
+		links, err := src.ExtractLinks()
+		if err != nil {
+			return nil, err
+		}
+
+		child.Debug("extracted links", slog.Any("links", links))
+
+		e.Body = rendered.HTML
+		e.ChromaCSS = rendered.ChromaCSS
+		e.Unsupported = rendered.Unsupported
+		e.TOC = rendered.TOC
+		e.Links = *links
+		e.Resolved = res.Resolved
+
+		e.Images = make(map[string]string)
+		for _, p := range rendered.Images {
+			e.Images[p] = cache.Stamp(p)
+		}
+	}
+
+	if s.Cache != nil {
+		s.Cache.SetEntry(np.String(), *e)
+	}
+
+	return e, nil
+}
+
+// tocTransformer returns the options of the table of contents of a source.
+// Markers place it even without `toc: true`, unless it goes in the sidebar.
+func tocTransformer(s *state.State, md *metadata.Metadata) toc.Transformer {
+	c := s.Config.TOC.For(md)
+
+	return toc.Transformer{
+		Title:    c.Title,
+		MinDepth: c.MinLevel,
+		MaxDepth: c.MaxLevel,
+		AtMarker: !md.TOC || !c.Aside(),
+		AtTop:    md.TOC && !c.Aside(),
+	}
+}
+
+// resolvesSame reports whether the wikilink targets of a cached entry still
+// resolve to the same sources
+func resolvesSame(s *state.State, np nodepath.NodePath, resolved map[string]string) bool {
+	for target, rel := range resolved {
+		ref, _ := s.Resolver.Resolve(np, target)
+		if s.Resolver.Rel(ref) != rel {
+			return false
+		}
+	}
+
+	return true
+}
+
+// imagePipeline returns the configured image pipeline, or nil if disabled
+func imagePipeline(s *state.State) *imageproc.Pipeline {
+	if !s.Config.Images.Enable {
+		return nil
+	}
+
+	return &imageproc.Pipeline{
+		Sizes:   s.Config.Images.Sizes,
+		Widths:  s.Config.Images.Widths,
+		Quality: s.Config.Images.Quality,
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"slices"
 
 	"github.com/mattn/go-zglob"
+	"github.com/mstcl/pher/v3/internal/cache"
+	"github.com/mstcl/pher/v3/internal/imageproc"
 	"github.com/mstcl/pher/v3/internal/nodepath"
 	"github.com/mstcl/pher/v3/internal/state"
 	"golang.org/x/sync/errgroup"
@@ -120,11 +122,61 @@ func copyUserAssets(ctx context.Context, s *state.State) error {
 			outputPath := filepath.Join(s.OutputDir, relToInputDir)
 			parentOutputDir := filepath.Dir(outputPath)
 
+			// Images are resized and re-encoded instead of copied
+			pipeline := imagePipeline(s)
+			isImage := pipeline != nil && imageproc.Supported(assetPath.String())
+
+			// Skip assets unchanged since the previous build
+			if s.Cache != nil {
+				stamp := cache.Stamp(assetPath.String())
+				if len(stamp) == 0 {
+					return fmt.Errorf("os.Stat %s: %w", assetPath, fs.ErrNotExist)
+				}
+
+				if s.Cache.Fresh(outputPath, stamp) {
+					child.Debug("skipping unchanged asset")
+
+					// keep the variants of unchanged images too
+					if isImage {
+						variants, err := pipeline.Variants(assetPath.String())
+						if err != nil {
+							return err
+						}
+
+						for _, v := range variants {
+							rel, _ := filepath.Rel(s.InputDir, v)
+							s.Cache.Keep(filepath.Join(s.OutputDir, rel))
+						}
+					}
+
+					return nil
+				}
+			}
+
 			// Make equivalent directory in output directory
 			if err := os.MkdirAll(parentOutputDir, 0o755); err != nil {
 				return fmt.Errorf("os.MkdirAll %s: %v", parentOutputDir, err)
 			}
 
+			if isImage {
+				variants, err := pipeline.Process(assetPath.String(), outputPath)
+				if err != nil {
+					child.Warn("copying image as is, processing failed", slog.Any("error", err))
+
+					return copyFile(assetPath.String(), outputPath, 0o644)
+				}
+
+				child.Debug("processed image", slog.Any("variants", variants))
+
+				if s.Cache != nil {
+					for _, v := range variants {
+						s.Cache.Fresh(v, cache.Stamp(assetPath.String()))
+					}
+				}
+
+				return nil
+			}
+
 			// Copy file to target directory
 			return copyFile(assetPath.String(), outputPath, 0o644)
 		})
@@ -133,7 +185,8 @@ func copyUserAssets(ctx context.Context, s *state.State) error {
 	return eg.Wait()
 }
 
-// copyStatic
+// copyStatic copies the embedded static files, then the user static files,
+// to the output directory
 func copyStatic(s *state.State) error {
 	outputDir := filepath.Join(s.OutputDir, relStaticOutputDir)
 
@@ -197,5 +250,71 @@ func copyStatic(s *state.State) error {
 
 	Logger.Debug("walked static subfilesystem", slog.String("outputDir", outputDir))
 
+	// overlay user static files on top of the embedded ones
+	userDir := userStaticDir(s)
+	if len(userDir) == 0 {
+		return nil
+	}
+
+	if err := filepath.WalkDir(userDir, func(inputPath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(userDir, inputPath)
+		if err != nil {
+			return err
+		}
+
+		outputPath := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+			return fmt.Errorf("os.MkdirAll %s: %w", filepath.Dir(outputPath), err)
+		}
+
+		return copyFile(inputPath, outputPath, 0o644)
+	}); err != nil {
+		return fmt.Errorf("filepath.WalkDir: %w", err)
+	}
+
+	Logger.Debug("copied user static files", slog.String("dir", userDir))
+
+	return nil
+}
+
+// userStaticDir returns the directory of user static files: the configured
+// staticDir, else layouts/static in the input directory if it exists
+func userStaticDir(s *state.State) string {
+	if len(s.Config.StaticDir) > 0 {
+		return resolveConfigPath(s, s.Config.StaticDir)
+	}
+
+	dir := filepath.Join(s.InputDir, relLayoutsDir, relStaticOutputDir)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir
+	}
+
+	return ""
+}
+
+// loadCache loads the build cache from the configured cache directory, which
+// is relative to the configuration file.
+func loadCache(s *state.State) error {
+	cacheDir := resolveConfigPath(s, s.Config.CacheDir)
+
+	configContents, err := os.ReadFile(s.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile %s: %w", s.ConfigFile, err)
+	}
+
+	tmplHash, err := templateHash(s)
+	if err != nil {
+		return fmt.Errorf("hash templates: %w", err)
+	}
+
+	s.Cache, err = cache.Load(cacheDir, cache.Hash(configContents), tmplHash)
+	if err != nil {
+		return fmt.Errorf("load cache: %w", err)
+	}
+
 	return nil
 }
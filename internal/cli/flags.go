@@ -2,18 +2,95 @@ package cli
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mstcl/pher/v3/internal/state"
 )
 
-func parseFlags(s *state.State) {
-	flag.BoolVar(&s.ShowVersion, "v", false, "Show version and exit")
-	flag.BoolVar(&s.DryRun, "d", false, "Don't render (dry run)")
-	flag.BoolVar(&s.Debug, "debug", false, "Verbose (debug) mode")
+const (
+	cmdBuild = "build"
+	cmdServe = "serve"
+	cmdCheck = "check"
+)
+
+// parseFlags parses args onto the state. The first argument is treated as a
+// subcommand if it isn't a flag.
+func parseFlags(s *state.State, args []string) error {
+	s.Command = cmdBuild
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		s.Command = args[0]
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("pher "+s.Command, flag.ExitOnError)
+	fs.SetOutput(os.Stderr)
+
+	fs.BoolVar(&s.ShowVersion, "v", false, "Show version and exit")
+	fs.BoolVar(&s.DryRun, "d", false, "Don't render (dry run)")
+	fs.BoolVar(&s.Debug, "debug", false, "Verbose (debug) mode")
+
+	fs.StringVar(&s.ConfigFile, "c", "config.yaml", "Path to config file")
+	fs.StringVar(&s.InputDir, "i", ".", "Input directory")
+
+	fs.BoolVar(&s.BuildFuture, "build-future", false, "Include pages published in the future")
+	fs.BoolVar(&s.BuildExpired, "build-expired", false, "Include pages past their expiry date")
+
+	now := fs.String("now", "", "Build as of this time (YYYY-MM-DD, RFC3339 or @unix seconds, e.g. @$SOURCE_DATE_EPOCH), defaults to the current time")
+
+	switch s.Command {
+	case cmdServe:
+		fs.StringVar(&s.OutputDir, "o", "", "Output directory (default temporary directory)")
+		fs.StringVar(&s.Addr, "addr", "localhost:8080", "Address to listen on")
+		fs.BoolVar(&s.Strict, "strict", false, "Fail on broken links and missing assets")
+	case cmdCheck:
+		fs.StringVar(&s.CheckFormat, "format", "text", "Output format of problems (text or json)")
+	default:
+		fs.StringVar(&s.OutputDir, "o", "_site", "Output directory")
+		fs.StringVar(&s.Since, "since", "", "Only render pages changed since this git revision, and their dependents")
+		fs.BoolVar(&s.Strict, "strict", false, "Fail on broken links and missing assets")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return setClock(s, *now)
+}
+
+// setClock fixes the reference time of builds to now if given, as a date,
+// an RFC3339 time or seconds since the epoch prefixed with @.
+// $SOURCE_DATE_EPOCH isn't used implicitly: packagers set it to the time of
+// a commit, or to 1980, which would unpublish every page dated after it.
+func setClock(s *state.State, now string) error {
+	if len(now) == 0 {
+		return nil
+	}
+
+	var t time.Time
+
+	if epoch, ok := strings.CutPrefix(now, "@"); ok {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse -now: %w", err)
+		}
+
+		t = time.Unix(sec, 0).UTC()
+	} else {
+		var err error
+
+		t, err = time.Parse(time.RFC3339, now)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", now); err != nil {
+				return fmt.Errorf("parse -now: %w", err)
+			}
+		}
+	}
 
-	flag.StringVar(&s.ConfigFile, "c", "config.yaml", "Path to config file")
-	flag.StringVar(&s.InputDir, "i", ".", "Input directory")
-	flag.StringVar(&s.OutputDir, "o", "_site", "Output directory")
+	s.Clock = func() time.Time { return t }
 
-	flag.Parse()
+	return nil
 }
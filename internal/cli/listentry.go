@@ -198,6 +198,12 @@ func populateNodePathLinksHelper(
 			continue
 		}
 
+		if s.NodeMap[np].Metadata.Hidden() {
+			childLogger.Debug("skipping draft file")
+
+			continue
+		}
+
 		// checks complete, now we consider only files that are valid
 
 		// don't render these files later
@@ -220,6 +226,10 @@ func populateNodePathLinksHelper(
 		// prepare the link
 		l := nodepathlink.NodePathLink{}
 
+		// the filename is the sort key of last resort, take it before np
+		// is switched to the index of nodegroups
+		filename := filepath.Base(np.String())
+
 		// grab href target, different for file vs. dir
 		l.IsDir = IsDir
 
@@ -282,7 +292,7 @@ func populateNodePathLinksHelper(
 			// if date is present convert it
 			date := s.NodeMap[np].Metadata.Date
 			if len(date) > 0 {
-				l.Date, l.MachineDate, err = convert.Date(date)
+				l.Date, l.MachineDate, err = convert.Date(date, s.Config.Location, s.Config.DateFormat)
 				if err != nil {
 					return err
 				}
@@ -291,7 +301,7 @@ func populateNodePathLinksHelper(
 			// if dateUpdated is present convert it
 			dateUpdated := s.NodeMap[np].Metadata.DateUpdated
 			if len(dateUpdated) > 0 {
-				l.DateUpdated, l.MachineDateUpdated, err = convert.Date(dateUpdated)
+				l.DateUpdated, l.MachineDateUpdated, err = convert.Date(dateUpdated, s.Config.Location, s.Config.DateFormat)
 				if err != nil {
 					return err
 				}
@@ -301,17 +311,41 @@ func populateNodePathLinksHelper(
 			l.Tags = s.NodeMap[np].Metadata.Tags
 		}
 
-		// if node is pinned we prepend it to the links map slice value, else we append it
-		if s.NodeMap[np].Metadata.Pinned {
-			s.NodePathLinksMap[nodegroupIndexPath] = append(
-				[]nodepathlink.NodePathLink{l},
-				s.NodePathLinksMap[nodegroupIndexPath]...)
+		l.Keys = sortKeys(s, s.NodeMap[np].Metadata, filename)
 
-			continue
-		} else {
-			s.NodePathLinksMap[nodegroupIndexPath] = append(s.NodePathLinksMap[nodegroupIndexPath], l)
-		}
+		s.NodePathLinksMap[nodegroupIndexPath] = append(s.NodePathLinksMap[nodegroupIndexPath], l)
+	}
+
+	// sort the links, pinned ones first
+	sortBy := s.NodeMap[nodegroupIndexPath].Metadata.Sort
+	if len(sortBy) == 0 {
+		sortBy = s.Config.Sort
+	}
+
+	if err := nodepathlink.Sort(s.NodePathLinksMap[nodegroupIndexPath], sortBy); err != nil {
+		Logger.Warn(
+			"unknown sort, sorting by filename",
+			slog.Any("nodepath", nodegroupIndexPath),
+			slog.String("sort", sortBy),
+		)
+
+		_ = nodepathlink.Sort(s.NodePathLinksMap[nodegroupIndexPath], "filename")
 	}
 
 	return nil
 }
+
+// sortKeys returns the keys a link to a source with metadata md is sorted by.
+// Invalid dates are left zero, they are reported when rendering.
+func sortKeys(s *state.State, md metadata.Metadata, filename string) nodepathlink.Keys {
+	date, _ := convert.ParseDate(md.Date, s.Config.Location)
+	updated, _ := convert.ParseDate(md.DateUpdated, s.Config.Location)
+
+	return nodepathlink.Keys{
+		Date:     date,
+		Updated:  updated,
+		Filename: filename,
+		Weight:   md.Weight,
+		Pinned:   md.Pinned,
+	}
+}
@@ -49,6 +49,16 @@ func sanitize(s *state.State) error {
 	return nil
 }
 
+// resolveConfigPath resolves p, a path set in the configuration, relative to
+// the configuration file
+func resolveConfigPath(s *state.State, p string) string {
+	if len(p) == 0 || filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(filepath.Dir(s.ConfigFile), p)
+}
+
 // reorderNodeFiles resorts nodes slice so that all group index are moved to the
 // end so they are processed last
 func reorderNodeFiles(nodepaths []nodepath.NodePath) []nodepath.NodePath {
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mstcl/pher/v3/internal/livereload"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+// serve builds the site, serves it over HTTP and rebuilds whenever the input
+// directory, the configuration or the templates change. Connected browsers
+// are told to reload after each successful rebuild.
+func serve(s *state.State) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// build into a throwaway directory unless told otherwise
+	if len(s.OutputDir) == 0 {
+		tmp, err := os.MkdirTemp("", "pher-serve-*")
+		if err != nil {
+			return fmt.Errorf("os.MkdirTemp: %w", err)
+		}
+
+		defer os.RemoveAll(tmp)
+
+		s.OutputDir = tmp
+	}
+
+	if err := sanitize(s); err != nil {
+		return err
+	}
+
+	s.LiveReload = livereload.Endpoint
+
+	broker := livereload.New()
+
+	// sitePath is the configured subpath of the latest successful build, read
+	// by the HTTP handler on every request
+	var (
+		mu       sync.RWMutex
+		sitePath = "/"
+	)
+
+	// rebuild is never run concurrently: the watcher calls it sequentially
+	rebuild := func() error {
+		s.Reset()
+
+		if err := build(s); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		sitePath = s.Config.Path
+		mu.Unlock()
+
+		return nil
+	}
+
+	if err := rebuild(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(livereload.Endpoint, broker)
+	mux.Handle("/", siteHandler(s.OutputDir, func() string {
+		mu.RLock()
+		defer mu.RUnlock()
+
+		return sitePath
+	}))
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// watch sources, ignoring our own output if it lives in the input directory
+	w := newWatcher(watchPaths(s), []string{s.OutputDir})
+
+	go w.run(ctx, func() {
+		Logger.Info("change detected, rebuilding")
+
+		if err := rebuild(); err != nil {
+			Logger.Error(fmt.Sprintf("rebuild failed: %v", err))
+
+			return
+		}
+
+		broker.Reload()
+	})
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	mu.RLock()
+	url := "http://" + s.Addr + sitePath
+	mu.RUnlock()
+
+	Logger.Info("serving", slog.String("url", url), slog.String("dir", s.OutputDir))
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+
+	return nil
+}
+
+// watchPaths returns the paths that should trigger a rebuild when changed
+func watchPaths(s *state.State) []string {
+	return existingPaths(s.InputDir, s.ConfigFile, userTemplateDir(s), userStaticDir(s))
+}
+
+// siteHandler serves dir under the configured subpath. With keepExtension
+// disabled hrefs have no extension, so /a/b is served from a/b.html.
+func siteHandler(dir string, sitePath func() string) http.Handler {
+	fileServer := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimSuffix(sitePath(), "/")
+
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		rel := strings.TrimPrefix(r.URL.Path, prefix)
+
+		if path.Ext(rel) == "" && !strings.HasSuffix(rel, "/") {
+			info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)+".html"))
+			if err == nil && info.Mode().IsRegular() {
+				rel += ".html"
+			}
+		}
+
+		r = r.Clone(r.Context())
+		r.URL.Path = rel
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/git"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/source"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+// planPartialBuild diffs the input directory against the git revision
+// s.Since and sets s.RenderOnly to the changed pages plus every page whose
+// listing, backlinks or related links depend on them. Pages of deleted
+// sources, and of changed sources that are now drafts or unpublished, are
+// removed from the output directory.
+//
+// Must be called after extractExtras() and populateNodePathLinks().
+func planPartialBuild(s *state.State) error {
+	repo, err := git.Open(s.InputDir)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	commitHash, err := repo.Resolve(s.Since)
+	if err != nil {
+		return err
+	}
+
+	commit, err := repo.Commit(commitHash)
+	if err != nil {
+		return err
+	}
+
+	files, err := repo.Files(commit.Tree)
+	if err != nil {
+		return err
+	}
+
+	Logger.Debug("read git tree", slog.String("commit", commitHash.String()), slog.Int("files", len(files)))
+
+	// old: sources in the revision, with the same filtering as getNodePaths
+	old := make(map[nodepath.NodePath]git.Hash)
+
+	for p, h := range files {
+		if path.Ext(p) != ".md" {
+			continue
+		}
+
+		abs := filepath.Join(repo.Root, filepath.FromSlash(p))
+
+		rel, err := filepath.Rel(s.InputDir, abs)
+		if err != nil || strings.HasPrefix(rel, "..") || isPathHidden(rel) {
+			continue
+		}
+
+		old[nodepath.NodePath(abs)] = h
+	}
+
+	// changed: sources that are new or differ from the revision
+	// removed: sources that only exist in the revision
+	// renames are both: the old page is removed and the new one rendered
+	var changed, removed []nodepath.NodePath
+
+	current := make(map[nodepath.NodePath]git.Hash)
+
+	for _, np := range s.NodePaths {
+		// generated indexes have no source
+		if s.NodegroupWithoutIndexMap[np] {
+			continue
+		}
+
+		b, err := os.ReadFile(np.String())
+		if err != nil {
+			return fmt.Errorf("os.ReadFile %s: %w", np, err)
+		}
+
+		current[np] = git.BlobHash(b)
+
+		if h, ok := old[np]; !ok || h != current[np] {
+			changed = append(changed, np)
+		}
+	}
+
+	for np := range old {
+		if _, ok := current[np]; !ok {
+			removed = append(removed, np)
+		}
+	}
+
+	Logger.Debug("diffed input directory", slog.Any("changed", changed), slog.Any("removed", removed))
+
+	renderOnly := make(map[nodepath.NodePath]bool)
+
+	// tags of old and new versions: pages sharing them have related links
+	tags := make(map[string]bool)
+
+	// hrefs of changed pages: pages listing them as backlinks need updating
+	hrefs := make(map[string]bool)
+
+	for _, np := range changed {
+		renderOnly[np] = true
+		hrefs[s.NodeMap[np].Href] = true
+
+		for _, t := range s.NodeMap[np].Metadata.Tags {
+			tags[t] = true
+		}
+	}
+
+	// pages that old versions linked to lose their backlinks
+	for _, np := range slices.Concat(changed, removed) {
+		h, ok := old[np]
+		if !ok {
+			continue
+		}
+
+		blob, err := repo.Blob(h)
+		if err != nil {
+			return err
+		}
+
+		src := source.Source{Body: blob}
+
+		md, err := src.ExtractMetadata()
+		if err != nil {
+			return fmt.Errorf("%s at %s: %w", np, s.Since, err)
+		}
+
+		for _, t := range md.Tags {
+			tags[t] = true
+		}
+
+		links, err := src.ExtractLinks()
+		if err != nil {
+			return fmt.Errorf("%s at %s: %w", np, s.Since, err)
+		}
+
+		for _, v := range links.BackLinks {
+			if ref, _ := s.Resolver.Resolve(np, v); len(ref) > 0 {
+				renderOnly[ref] = true
+			}
+		}
+	}
+
+	for np, entry := range s.NodeMap {
+		// pages embedding changed pages
+		for _, dep := range s.EmbedsMap[np] {
+			if slices.Contains(changed, dep) {
+				renderOnly[np] = true
+			}
+		}
+
+		// pages that current versions link to
+		for _, l := range entry.Backlinks {
+			if hrefs[l.Href] {
+				renderOnly[np] = true
+			}
+		}
+
+		// pages sharing tags
+		for _, t := range entry.Metadata.Tags {
+			if tags[t] {
+				renderOnly[np] = true
+			}
+		}
+	}
+
+	// listings of all parent nodegroups
+	for _, np := range slices.Concat(changed, removed) {
+		for dir := filepath.Dir(np.String()); ; dir = filepath.Dir(dir) {
+			renderOnly[nodepath.NodePath(filepath.Join(dir, "index.md"))] = true
+
+			if dir == s.InputDir || !strings.HasPrefix(dir, s.InputDir) {
+				break
+			}
+		}
+	}
+
+	// pages of changed nodegroups may link to the nodegroup feed
+	for _, np := range slices.Concat(changed, removed) {
+		dir := filepath.Dir(np.String())
+		if np.Base() != "index" || dir == s.InputDir {
+			continue
+		}
+
+		for other := range s.NodeMap {
+			if strings.HasPrefix(other.String(), dir+string(filepath.Separator)) {
+				renderOnly[other] = true
+			}
+		}
+	}
+
+	// the root index lists all tags
+	renderOnly[nodepath.NodePath(filepath.Join(s.InputDir, "index.md"))] = true
+
+	s.RenderOnly = renderOnly
+
+	// remove pages of deleted sources, and of sources now hidden
+	if s.DryRun {
+		return nil
+	}
+
+	stale := slices.Clone(removed)
+
+	for _, np := range changed {
+		if s.NodeMap[np].Metadata.Hidden() {
+			stale = append(stale, np)
+		}
+	}
+
+	for _, np := range stale {
+		outPath := s.OutputDir + np.Href(s.InputDir, true) + ".html"
+		if err := os.Remove(outPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("os.Remove %s: %w", outPath, err)
+		}
+
+		Logger.Debug("removed page of deleted or hidden source", slog.String("path", outPath))
+	}
+
+	return nil
+}
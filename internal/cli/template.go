@@ -2,24 +2,133 @@ package cli
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
 	"path"
 	"path/filepath"
 
+	"github.com/mstcl/pher/v3/internal/cache"
+	"github.com/mstcl/pher/v3/internal/convert"
 	"github.com/mstcl/pher/v3/internal/state"
+	"github.com/mstcl/pher/v3/internal/tag"
 )
 
 var EmbedFS embed.FS
 
-func initTemplates(s *state.State) {
+// initTemplates parses the embedded templates, then the user templates on
+// top. Since templates are looked up by their {{define}} name, a user file
+// only needs to redefine the templates it changes.
+func initTemplates(s *state.State) error {
 	funcMap := getTemplateFuncMap()
 	tmpl := template.New("main")
 	tmpl = tmpl.Funcs(funcMap)
-	s.Templates = template.Must(tmpl.ParseFS(EmbedFS, filepath.Join(relTemplateDir, "*")))
+
+	tmpl, err := tmpl.ParseFS(EmbedFS, path.Join(relTemplateDir, "*"))
+	if err != nil {
+		return fmt.Errorf("parse embedded templates: %w", err)
+	}
+
+	files, err := userTemplateFiles(s)
+	if err != nil {
+		return err
+	}
+
+	// parse user templates one at a time, named by their full path, so
+	// errors point at the offending file and line
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile %s: %w", f, err)
+		}
+
+		if _, err := tmpl.New(f).Parse(string(b)); err != nil {
+			return fmt.Errorf("parse user template: %w", err)
+		}
+
+		Logger.Debug("parsed user template", slog.String("file", f))
+	}
+
+	s.Templates = tmpl
+
+	return nil
 }
 
 func getTemplateFuncMap() template.FuncMap {
 	return template.FuncMap{
-		"joinPath": path.Join,
+		"joinPath":   path.Join,
+		"tagSlug":    tag.Slug,
+		"formatDate": convert.FormatDate,
 	}
 }
+
+// userTemplateDir returns the directory of user templates: the configured
+// templateDir, else layouts in the input directory if it exists
+func userTemplateDir(s *state.State) string {
+	if len(s.Config.TemplateDir) > 0 {
+		return resolveConfigPath(s, s.Config.TemplateDir)
+	}
+
+	dir := filepath.Join(s.InputDir, relLayoutsDir)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir
+	}
+
+	return ""
+}
+
+// userTemplateFiles returns the user template files, in lexical order
+func userTemplateFiles(s *state.State) ([]string, error) {
+	dir := userTemplateDir(s)
+	if len(dir) == 0 {
+		return nil, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("glob templates: %w", err)
+	}
+
+	return files, nil
+}
+
+// templateHash hashes the contents of all templates, embedded and user
+// provided, so cached pages are retemplated when any of them changes
+func templateHash(s *state.State) (string, error) {
+	var contents [][]byte
+
+	if err := fs.WalkDir(EmbedFS, relTemplateDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := EmbedFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		contents = append(contents, []byte(p), b)
+
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	files, err := userTemplateFiles(s)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("os.ReadFile %s: %w", f, err)
+		}
+
+		contents = append(contents, []byte(f), b)
+	}
+
+	return cache.Hash(contents...), nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const watchInterval = 500 * time.Millisecond
+
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+// watcher polls a set of files and directories for changes. We poll instead
+// of relying on OS notifications so pher stays dependency-free and behaves
+// the same on every platform.
+type watcher struct {
+	snapshot map[string]fileStamp
+	paths    []string
+	ignore   []string
+}
+
+func newWatcher(paths []string, ignore []string) *watcher {
+	w := &watcher{paths: paths, ignore: ignore}
+	w.snapshot = w.scan()
+
+	return w
+}
+
+// isIgnored reports whether p is (or is inside) an ignored path, or is hidden
+// relative to the watched root.
+func (w *watcher) isIgnored(root string, p string) bool {
+	for _, i := range w.ignore {
+		if p == i || strings.HasPrefix(p, i+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+
+	return isPathHidden(rel)
+}
+
+// scan walks all watched paths and records their modification time and size
+func (w *watcher) scan() map[string]fileStamp {
+	snapshot := make(map[string]fileStamp)
+
+	for _, root := range w.paths {
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			// files may disappear mid-walk, just skip them
+			if err != nil {
+				return nil
+			}
+
+			if w.isIgnored(root, p) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			snapshot[p] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+
+			return nil
+		})
+	}
+
+	return snapshot
+}
+
+// changed rescans the watched paths and reports whether anything was added,
+// removed or modified since the last call.
+func (w *watcher) changed() bool {
+	snapshot := w.scan()
+	changed := !maps.Equal(snapshot, w.snapshot)
+	w.snapshot = snapshot
+
+	return changed
+}
+
+// run polls until ctx is cancelled, calling onChange after each batch of
+// changes.
+func (w *watcher) run(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+
+			Logger.Debug("detected changes", slog.Any("paths", w.paths))
+
+			onChange()
+		}
+	}
+}
+
+// existingPaths filters out paths that don't exist
+func existingPaths(paths ...string) []string {
+	var existing []string
+
+	for _, p := range paths {
+		if len(p) == 0 {
+			continue
+		}
+
+		if _, err := os.Stat(p); err == nil {
+			existing = append(existing, p)
+		}
+	}
+
+	return existing
+}
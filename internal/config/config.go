@@ -3,26 +3,54 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"time"
+	_ "time/tzdata" // timezones without relying on the system's
 
+	"github.com/mstcl/pher/v3/internal/metadata"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Title         string       `yaml:"title"`
-	Description   string       `yaml:"description"`
-	Url           string       `yaml:"url"`
-	AuthorName    string       `yaml:"authorName"`
-	AuthorEmail   string       `yaml:"authorEmail"`
-	RootCrumb     string       `yaml:"rootCrumb"`
-	Path          string       `yaml:"path"`
-	Head          string       `yaml:"head"`
-	CodeTheme     string       `yaml:"codeTheme"`
-	Footer        []FooterLink `yaml:"footer"`
-	CodeHighlight bool         `yaml:"codeHighlight"`
-	IsExt         bool         `yaml:"keepExtension"`
+	Title         string          `yaml:"title"`
+	Description   string          `yaml:"description"`
+	Url           string          `yaml:"url"`
+	AuthorName    string          `yaml:"authorName"`
+	AuthorEmail   string          `yaml:"authorEmail"`
+	RootCrumb     string          `yaml:"rootCrumb"`
+	Path          string          `yaml:"path"`
+	Head          string          `yaml:"head"`
+	CodeTheme     string          `yaml:"codeTheme"`
+	Timezone      string          `yaml:"timezone"`
+	DateFormat    string          `yaml:"dateFormat"`
+	Dates         string          `yaml:"dates"`
+	Sort          string          `yaml:"sort"`
+	CacheDir      string          `yaml:"cacheDir"`
+	TemplateDir   string          `yaml:"templateDir"`
+	StaticDir     string          `yaml:"staticDir"`
+	Footer        []FooterLink    `yaml:"footer"`
+	Feeds         []FeedConfig    `yaml:"feeds"`
+	Images        ImageConfig     `yaml:"images"`
+	Search        SearchConfig    `yaml:"search"`
+	Robots        RobotsConfig    `yaml:"robots"`
+	Redirects     RedirectsConfig `yaml:"redirects"`
+	Graph         GraphConfig     `yaml:"graph"`
+	Diagrams      DiagramsConfig  `yaml:"diagrams"`
+	TOC           TOCConfig       `yaml:"toc"`
+	Archive       ArchiveConfig   `yaml:"archive"`
+	EmbedDepth    int             `yaml:"embedDepth"`
+	CodeHighlight bool            `yaml:"codeHighlight"`
+	IsExt         bool            `yaml:"keepExtension"`
+	Cache         bool            `yaml:"cache"`
+	Sitemap       bool            `yaml:"sitemap"`
+	Math          bool            `yaml:"math"`
+	TagFeeds      bool            `yaml:"tagFeeds"`
+
+	// Location is the loaded Timezone
+	Location *time.Location `yaml:"-"`
 }
 
 type FooterLink struct {
@@ -30,6 +58,109 @@ type FooterLink struct {
 	Text string `yaml:"text"`
 }
 
+// FeedConfig configures a feed written in Format (atom, rss or json) to
+// Filename, relative to the output directory.
+type FeedConfig struct {
+	Format   string `yaml:"format"`
+	Filename string `yaml:"filename"`
+}
+
+// ImageConfig configures the image pipeline. Linked JPEG and PNG images are
+// re-encoded and resized to each of Widths smaller than the original.
+type ImageConfig struct {
+	Sizes   string `yaml:"sizes"`
+	Widths  []int  `yaml:"widths"`
+	Quality int    `yaml:"quality"`
+	Enable  bool   `yaml:"enable"`
+}
+
+// SearchConfig configures the client-side search index. With Shards > 1,
+// the index is split across that many files.
+type SearchConfig struct {
+	Shards          int  `yaml:"shards"`
+	Enable          bool `yaml:"enable"`
+	IncludeUnlisted bool `yaml:"includeUnlisted"`
+}
+
+// RobotsConfig configures robots.txt. The sitemap is appended to Rules.
+type RobotsConfig struct {
+	Rules  string `yaml:"rules"`
+	Enable bool   `yaml:"enable"`
+}
+
+// RedirectsConfig configures files redirecting the aliases of pages, for
+// Netlify (_redirects) and nginx (redirects.map). Stub pages are always
+// rendered.
+type RedirectsConfig struct {
+	Netlify bool `yaml:"netlify"`
+	Nginx   bool `yaml:"nginx"`
+}
+
+// GraphConfig configures the export of the link graph to graph.json, and
+// optionally graph.dot and graph.graphml. Page renders graph.html, drawing the
+// neighbourhood of each note.
+type GraphConfig struct {
+	Enable          bool `yaml:"enable"`
+	Dot             bool `yaml:"dot"`
+	GraphML         bool `yaml:"graphml"`
+	Page            bool `yaml:"page"`
+	IncludeUnlisted bool `yaml:"includeUnlisted"`
+}
+
+// DiagramsConfig configures diagrams. Mermaid diagrams are drawn on the
+// client, by loading the MermaidScript ES module on pages with any. A
+// relative MermaidScript is relative to the site path. pher doesn't ship
+// mermaid, so MermaidScript is required with Mermaid.
+type DiagramsConfig struct {
+	MermaidScript string `yaml:"mermaidScript"`
+	Mermaid       bool   `yaml:"mermaid"`
+}
+
+// ArchiveConfig configures archive.html, listing the dated pages by year and
+// month. Years also renders a page per year to archive/<year>.html.
+type ArchiveConfig struct {
+	Enable bool `yaml:"enable"`
+	Years  bool `yaml:"years"`
+}
+
+// TOCConfig configures the tables of contents of pages with `toc: true`,
+// listing headings from MinLevel to MaxLevel. Placement is "inline" (at the
+// top of the body, or at a [[_TOC_]] marker) or "aside" (in the sidebar).
+// Pages can override each in their frontmatter.
+type TOCConfig struct {
+	Title     string `yaml:"title"`
+	Placement string `yaml:"placement"`
+	MinLevel  int    `yaml:"minLevel"`
+	MaxLevel  int    `yaml:"maxLevel"`
+}
+
+// For returns the options of the table of contents of a page, overridden
+// by its frontmatter
+func (c TOCConfig) For(md *metadata.Metadata) TOCConfig {
+	if len(md.TOCTitle) > 0 {
+		c.Title = md.TOCTitle
+	}
+
+	if len(md.TOCPlacement) > 0 {
+		c.Placement = md.TOCPlacement
+	}
+
+	if md.TOCMinLevel > 0 {
+		c.MinLevel = md.TOCMinLevel
+	}
+
+	if md.TOCMaxLevel > 0 {
+		c.MaxLevel = md.TOCMaxLevel
+	}
+
+	return c
+}
+
+// Aside reports whether the table of contents goes in the sidebar
+func (c TOCConfig) Aside() bool {
+	return c.Placement == "aside"
+}
+
 func DefaultConfig() Config {
 	return Config{
 		CodeHighlight: true,
@@ -37,6 +168,33 @@ func DefaultConfig() Config {
 		RootCrumb:     "~",
 		Path:          "/",
 		CodeTheme:     "ashen",
+		Timezone:      "UTC",
+		DateFormat:    "02 Jan 2006",
+		Dates:         "frontmatter",
+		Location:      time.UTC,
+		Sort:          "filename",
+		CacheDir:      ".pher-cache",
+		EmbedDepth:    3,
+		Feeds: []FeedConfig{
+			{Format: "atom", Filename: "feed.xml"},
+		},
+		Images: ImageConfig{
+			Sizes:   "(max-width: 42rem) 100vw, 42rem",
+			Widths:  []int{480, 960, 1440},
+			Quality: 80,
+		},
+		Search: SearchConfig{
+			IncludeUnlisted: true,
+		},
+		TOC: TOCConfig{
+			Title:     "TOC",
+			Placement: "inline",
+			MinLevel:  2,
+			MaxLevel:  2,
+		},
+		Robots: RobotsConfig{
+			Rules: "User-agent: *\nAllow: /",
+		},
 	}
 }
 
@@ -62,5 +220,22 @@ func handleConfig(file io.Reader) (*Config, error) {
 		return nil, err
 	}
 
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("load timezone: %w", err)
+	}
+
+	cfg.Location = loc
+
+	switch cfg.Dates {
+	case "frontmatter", "git", "mtime":
+	default:
+		return nil, fmt.Errorf("unknown dates %q: expected frontmatter, git or mtime", cfg.Dates)
+	}
+
+	if cfg.Diagrams.Mermaid && len(cfg.Diagrams.MermaidScript) == 0 {
+		return nil, errors.New("diagrams.mermaid requires diagrams.mermaidScript")
+	}
+
 	return &cfg, nil
 }
@@ -3,6 +3,8 @@
 package convert
 
 import (
+	"fmt"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -10,19 +12,63 @@ import (
 	"github.com/mstcl/pher/v3/internal/nodepath"
 )
 
-// Date function resolves the date d (format YYYY-MM-DD)
-// Returns a pretty date and a machine date
-func Date(date string) (string, string, error) {
+// dateLayouts are the layouts dates are parsed with, in order. Those without
+// a timezone are in the timezone of the site.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseDate parses the date d, as YYYY-MM-DD, RFC3339 or a date-time with or
+// without a timezone, in loc (UTC if nil) unless d has a timezone. The date
+// is returned in loc, zero if d is empty.
+func ParseDate(date string, loc *time.Location) (time.Time, error) {
+	if len(date) == 0 {
+		return time.Time{}, nil
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, date, loc); err == nil {
+			return t.In(loc), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("parse date %q: expected YYYY-MM-DD or RFC3339", date)
+}
+
+// Date function resolves the date d (see ParseDate) in loc.
+// Returns a pretty date, formatted with layout, and a machine date (RFC3339)
+func Date(date string, loc *time.Location, layout string) (string, string, error) {
 	if len(date) == 0 {
 		return "", "", nil
 	}
 
-	dateTime, err := time.Parse("2006-01-02", date)
+	dateTime, err := ParseDate(date, loc)
 	if err != nil {
 		return "", "", err
 	}
 
-	return dateTime.Format("02 Jan 2006"), dateTime.Format(time.RFC3339), nil
+	return dateTime.Format(layout), dateTime.Format(time.RFC3339), nil
+}
+
+// FormatDate formats the machine date d (RFC3339) with layout, for templates.
+// Returns d as is if it can't be parsed.
+func FormatDate(date string, layout string) string {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+
+	return t.Format(layout)
 }
 
 // NavCrumbs returns navigation components
@@ -69,3 +115,38 @@ func Title(metadataTitle string, filename string) string {
 
 	return title
 }
+
+// EscapePath percent-encodes each segment of the slash-separated path p, so
+// it can be used in a URL, e.g. "notes/note one.html" is
+// "notes/note%20one.html"
+func EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// AbsURL returns the absolute url of href, a link relative to the root of the
+// site. The site subpath is only added if the path of siteURL doesn't already
+// end with its segments.
+//
+// AbsURL("https://example.org", "/wiki", "a/b.html") = "https://example.org/wiki/a/b.html"
+//
+// AbsURL("https://example.org/mywiki", "/wiki", "a.html") = "https://example.org/mywiki/wiki/a.html"
+func AbsURL(siteURL string, sitePath string, href string) string {
+	base := strings.TrimSuffix(siteURL, "/")
+	subpath := strings.Trim(sitePath, "/")
+
+	var basePath string
+	if u, err := url.Parse(base); err == nil {
+		basePath = u.Path
+	}
+
+	if len(subpath) > 0 && !strings.HasSuffix(basePath, "/"+subpath) {
+		base += "/" + subpath
+	}
+
+	return base + "/" + strings.TrimPrefix(href, "/")
+}
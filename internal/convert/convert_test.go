@@ -1,5 +1,11 @@
 package convert
 
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
 // func TestHref(t *testing.T) {
 // 	tests := []struct {
 // 		f    string
@@ -107,3 +113,79 @@ package convert
 // 		})
 // 	}
 // }
+
+func TestParseDate(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date string
+		loc  *time.Location
+		want string
+	}{
+		{"", nil, "0001-01-01T00:00:00Z"},
+		{"2024-03-05", nil, "2024-03-05T00:00:00Z"},
+		{"2024-03-05", london, "2024-03-05T00:00:00Z"},
+		{"2024-07-05", london, "2024-07-05T00:00:00+01:00"},
+		{"2024-07-05T10:30", london, "2024-07-05T10:30:00+01:00"},
+		{"2024-07-05 10:30:15", nil, "2024-07-05T10:30:15Z"},
+		{"2024-07-05T10:30:00+02:00", nil, "2024-07-05T08:30:00Z"},
+		{"2024-07-05T10:30:00.5Z", london, "2024-07-05T11:30:00+01:00"},
+		{"2024-07-05 10:30:00+02:00", london, "2024-07-05T09:30:00+01:00"},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s,%s", tt.date, tt.want)
+		t.Run(testname, func(t *testing.T) {
+			ans, err := ParseDate(tt.date, tt.loc)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+
+			if got := ans.Format(time.RFC3339); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateErrors(t *testing.T) {
+	for _, date := range []string{"05/03/2024", "2024-13-01", "yesterday", "2024-03-05T25:00"} {
+		t.Run(date, func(t *testing.T) {
+			if _, err := ParseDate(date, nil); err == nil {
+				t.Errorf("got no error, want one")
+			}
+		})
+	}
+}
+
+func TestAbsURL(t *testing.T) {
+	tests := []struct {
+		siteURL  string
+		sitePath string
+		href     string
+		want     string
+	}{
+		{"https://example.org", "/", "a/b.html", "https://example.org/a/b.html"},
+		{"https://example.org/", "", "/a.html", "https://example.org/a.html"},
+		{"https://example.org", "/wiki", "a/b.html", "https://example.org/wiki/a/b.html"},
+		{"https://example.org", "/wiki/", "/a.html", "https://example.org/wiki/a.html"},
+		{"https://example.org/wiki", "/wiki", "a.html", "https://example.org/wiki/a.html"},
+		{"https://example.org/wiki/", "wiki", "a.html", "https://example.org/wiki/a.html"},
+		{"https://example.org/mywiki", "/wiki", "a.html", "https://example.org/mywiki/wiki/a.html"},
+		{"https://wiki", "/wiki", "a.html", "https://wiki/wiki/a.html"},
+		{"https://example.org/docs/wiki", "/docs/wiki", "a.html", "https://example.org/docs/wiki/a.html"},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s,%s,%s", tt.siteURL, tt.sitePath, tt.href)
+		t.Run(testname, func(t *testing.T) {
+			ans := AbsURL(tt.siteURL, tt.sitePath, tt.href)
+			if ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,13 @@
 // Package customanchor defines a custom header anchor for goldmark/anchor
 package customanchor
 
-import "go.abhg.dev/goldmark/anchor"
+import (
+	"slices"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"go.abhg.dev/goldmark/anchor"
+)
 
 // Texter is the custom texter for heading anchors.
 type Texter struct{}
@@ -11,3 +17,25 @@ type Texter struct{}
 func (*Texter) AnchorText(h *anchor.HeaderInfo) []byte {
 	return []byte("#")
 }
+
+// ID returns the id of the first heading with the given text, as generated
+// by parser.WithAutoHeadingID(), e.g. "heading-text" for "Heading Text".
+func ID(text string) string {
+	return string(parser.NewContext().IDs().Generate([]byte(text), ast.KindHeading))
+}
+
+// HeadingID returns the id among ids, the heading IDs of a page, that the
+// fragment of a link refers to: the fragment itself, or the id of a heading
+// with the fragment as text as in [[page#Heading Text]]. It returns the
+// fragment and false if there is none.
+func HeadingID(ids []string, fragment string) (string, bool) {
+	if slices.Contains(ids, fragment) {
+		return fragment, true
+	}
+
+	if id := ID(fragment); slices.Contains(ids, id) {
+		return id, true
+	}
+
+	return fragment, false
+}
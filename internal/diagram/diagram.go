@@ -0,0 +1,172 @@
+// Package diagram renders fenced code blocks of diagram languages, e.g.
+// ```pikchr or ```mermaid, with a handler per language instead of as
+// highlighted code.
+package diagram
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/mstcl/pher/v3/internal/pikchr"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Handler renders the source of a diagram to HTML
+type Handler func(src []byte) ([]byte, error)
+
+// Handlers are the built-in handlers (key: language)
+var Handlers = map[string]Handler{
+	"mermaid": Mermaid,
+	"pikchr":  Pikchr,
+}
+
+// Pikchr draws the diagram at build time, to an inline <svg class="pikchr">
+func Pikchr(src []byte) ([]byte, error) {
+	return pikchr.Render(src)
+}
+
+// Mermaid leaves the diagram to be drawn by mermaid on the client, in a
+// <pre class="mermaid">
+func Mermaid(src []byte) ([]byte, error) {
+	return []byte(`<pre class="mermaid">` + html.EscapeString(string(src)) + "</pre>\n"), nil
+}
+
+// Kind is the kind of diagram AST nodes.
+var Kind = ast.NewNodeKind("Diagram")
+
+// Node is a diagram, replacing a fenced code block. Its lines hold the source
+// of the diagram.
+type Node struct {
+	ast.BaseBlock
+
+	// Language of the fenced code block
+	Language string
+}
+
+var _ ast.Node = (*Node)(nil)
+
+// Kind reports the kind of this node.
+func (n *Node) Kind() ast.NodeKind {
+	return Kind
+}
+
+// IsRaw reports that the lines of the diagram aren't markdown.
+func (n *Node) IsRaw() bool {
+	return true
+}
+
+// Dump dumps the Node to stdout.
+func (n *Node) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{
+		"Language": n.Language,
+	}, nil)
+}
+
+// Extender extends a goldmark Markdown object to render the fenced code
+// blocks of the languages of Handlers with them. These blocks bypass any
+// highlighting.
+//
+// * Handlers: handlers to use (key: language), defaults to the built-in
+// Handlers
+type Extender struct {
+	Handlers map[string]Handler
+}
+
+// Extend adds the diagram Transformer and Renderer to the provided Markdown
+// parser/renderer.
+func (e *Extender) Extend(md goldmark.Markdown) {
+	handlers := e.Handlers
+	if handlers == nil {
+		handlers = Handlers
+	}
+
+	md.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&Transformer{Handlers: handlers}, 100),
+		),
+	)
+
+	md.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&Renderer{Handlers: handlers}, 100),
+		),
+	)
+}
+
+// Transformer is a goldmark AST transformer that replaces the fenced code
+// blocks of diagram languages by diagrams.
+type Transformer struct {
+	Handlers map[string]Handler
+}
+
+var _ parser.ASTTransformer = (*Transformer)(nil) // interface compliance
+
+// Transform replaces the diagrams of the document.
+func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	blocks := []*ast.FencedCodeBlock{}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if b, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if _, ok := t.Handlers[string(b.Language(reader.Source()))]; ok {
+				blocks = append(blocks, b)
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	for _, b := range blocks {
+		d := &Node{Language: string(b.Language(reader.Source()))}
+		d.SetLines(b.Lines())
+		b.Parent().ReplaceChild(b.Parent(), b, d)
+	}
+}
+
+// Renderer renders diagrams with the handler of their language. Diagrams
+// that fail to render are replaced by the error and their source.
+type Renderer struct {
+	Handlers map[string]Handler
+}
+
+var _ renderer.NodeRenderer = (*Renderer)(nil)
+
+// RegisterFuncs registers diagram rendering functions with the provided
+// goldmark registerer.
+func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(Kind, r.render)
+}
+
+func (r *Renderer) render(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*Node)
+
+	var diagram bytes.Buffer
+
+	for i := range n.Lines().Len() {
+		seg := n.Lines().At(i)
+		diagram.Write(seg.Value(src))
+	}
+
+	out, err := r.Handlers[n.Language](diagram.Bytes())
+	if err != nil {
+		_, _ = w.WriteString(`<pre class="diagram-error">`)
+		_, _ = w.WriteString(html.EscapeString(n.Language + ": " + err.Error()))
+		_, _ = w.WriteString("\n\n")
+		_, _ = w.WriteString(html.EscapeString(diagram.String()))
+		_, _ = w.WriteString("</pre>\n")
+
+		return ast.WalkSkipChildren, nil
+	}
+
+	_, _ = w.Write(out)
+
+	return ast.WalkSkipChildren, nil
+}
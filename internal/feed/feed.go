@@ -1,78 +1,287 @@
-// Package feed handles RSS feed generation
+// Package feed handles Atom, RSS and JSON feed generation
 package feed
 
 import (
 	"fmt"
 	"log/slog"
 	"os"
-	"time"
+	"path"
+	"path/filepath"
+	"strings"
 
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/node"
+	"github.com/mstcl/pher/v3/internal/nodepath"
 	"github.com/mstcl/pher/v3/internal/state"
+	"github.com/mstcl/pher/v3/internal/tag"
 )
 
 var Logger *slog.Logger
 
-// Construct creates the RSS feed in memory
-func Construct(s *state.State) (string, error) {
-	now := time.Now()
+// Supported feed formats
+const (
+	FormatAtom = "atom"
+	FormatRss  = "rss"
+	FormatJSON = "json"
+)
+
+// Alternate is a feed linked from a page with <link rel="alternate">
+type Alternate struct {
+	Title, Href, Type string
+}
 
-	author := &Author{Name: s.Config.AuthorName, Email: s.Config.AuthorEmail}
-	feed := &Feed{
-		Title:       s.Config.Title,
-		Link:        &Link{Href: s.Config.Url},
-		Description: s.Config.Description,
-		Author:      author,
-		Created:     now,
+// Construct creates the feed of all dated nodes in memory
+func Construct(s *state.State) (*Feed, error) {
+	f := newFeed(s, s.Config.Title, s.Config.Description, s.Config.Url)
+
+	for _, v := range s.NodeMap {
+		if err := f.addNode(s, v); err != nil {
+			return nil, err
+		}
 	}
 
-	feed.Items = []*Item{}
+	f.sortItems()
+
+	return f, nil
+}
 
+// ConstructTag creates the feed of all dated nodes tagged with t in memory
+func ConstructTag(s *state.State, t tag.Tag) (*Feed, error) {
+	f := newFeed(
+		s,
+		fmt.Sprintf("%s: %s", s.Config.Title, t.Name),
+		s.Config.Description,
+		convert.AbsURL(s.Config.Url, s.Config.Path, tag.Href(t.Name, s.Config.IsExt)),
+	)
+
+	// tag links only carry the href of the node
+	nodes := make(map[string]node.Node)
 	for _, v := range s.NodeMap {
-		child := Logger.With(slog.String("href", v.Href), slog.String("context", "atom feed"))
+		nodes[v.Href] = v
+	}
 
-		md := v.Metadata
-		if len(md.Date) == 0 {
-			continue
+	for _, l := range t.Links {
+		if err := f.addNode(s, nodes[l.Href]); err != nil {
+			return nil, err
 		}
+	}
+
+	f.sortItems()
+
+	return f, nil
+}
 
-		t, err := time.Parse("2006-01-02", md.Date)
-		if err != nil {
-			return "", fmt.Errorf("parse time: %w", err)
+// ConstructNodegroup creates the feed of all dated nodes under the nodegroup
+// of the index np in memory
+func ConstructNodegroup(s *state.State, np nodepath.NodePath) (*Feed, error) {
+	index := s.NodeMap[np]
+	dir := filepath.Dir(np.String())
+
+	f := newFeed(
+		s,
+		fmt.Sprintf("%s: %s", s.Config.Title, convert.Title(index.Metadata.Title, filepath.Base(dir))),
+		index.Metadata.Description,
+		convert.AbsURL(s.Config.Url, s.Config.Path, index.Href),
+	)
+
+	for k, v := range s.NodeMap {
+		if k == np || !strings.HasPrefix(k.String(), dir+string(filepath.Separator)) {
+			continue
 		}
 
-		entry := &Item{
-			Title:       md.Title,
-			Link:        &Link{Href: s.Config.Url + v.Href},
-			Description: md.Description,
-			Author:      author,
-			Created:     t,
-			Content:     string(v.Body),
-			Categories:  md.Tags,
+		if err := f.addNode(s, v); err != nil {
+			return nil, err
 		}
+	}
 
-		feed.Items = append(feed.Items, entry)
+	f.sortItems()
 
-		child.Debug("atom entry created")
+	return f, nil
+}
+
+// newFeed creates an empty feed
+func newFeed(s *state.State, title string, description string, link string) *Feed {
+	return &Feed{
+		Title:       title,
+		Link:        &Link{Href: link},
+		Description: description,
+		Author:      &Author{Name: s.Config.AuthorName, Email: s.Config.AuthorEmail},
+		Created:     s.Clock(),
+		Items:       []*Item{},
+	}
+}
+
+// addNode adds v to the feed if it is dated and not a draft
+func (f *Feed) addNode(s *state.State, v node.Node) error {
+	child := Logger.With(slog.String("href", v.Href), slog.String("context", "feed"))
+
+	md := v.Metadata
+	if len(md.Date) == 0 || md.Hidden() {
+		return nil
 	}
 
-	atom, err := feed.ToAtom()
+	t, err := convert.ParseDate(md.Date, s.Config.Location)
 	if err != nil {
-		return "", fmt.Errorf("parse time: %w", err)
+		return err
+	}
+
+	f.Add(&Item{
+		Title:       md.Title,
+		Link:        &Link{Href: convert.AbsURL(s.Config.Url, s.Config.Path, v.Href)},
+		Description: md.Description,
+		Author:      f.Author,
+		Created:     t,
+		Content:     string(v.Body),
+		Categories:  md.Tags,
+	})
+
+	child.Debug("feed entry created")
+
+	return nil
+}
+
+// sortItems sorts the newest items first, by title at the same time for a
+// stable output
+func (f *Feed) sortItems() {
+	f.Sort(func(a, b *Item) bool {
+		if a.Created.Equal(b.Created) {
+			return a.Title < b.Title
+		}
+
+		return a.Created.After(b.Created)
+	})
+}
+
+// Format returns the feed in the given format
+func (f *Feed) Format(format string) (string, error) {
+	switch format {
+	case FormatAtom:
+		return f.ToAtom()
+	case FormatRss:
+		return f.ToRss()
+	case FormatJSON:
+		return f.ToJSON()
+	}
+
+	return "", fmt.Errorf("unknown feed format %q", format)
+}
+
+// MediaType returns the media type of the given feed format
+func MediaType(format string) string {
+	switch format {
+	case FormatRss:
+		return "application/rss+xml"
+	case FormatJSON:
+		return "application/feed+json"
+	}
+
+	return "application/atom+xml"
+}
+
+// Alternates returns the feeds to link to from the page of np: the
+// configured feeds, then the feeds of its nodegroups and of its tags in the
+// primary format. An empty np gives only the configured feeds.
+func Alternates(s *state.State, np nodepath.NodePath) []Alternate {
+	alternates := []Alternate{}
+
+	for _, c := range s.Config.Feeds {
+		alternates = append(alternates, Alternate{
+			Title: s.Config.Title,
+			Href:  path.Join(s.Config.Path, c.Filename),
+			Type:  MediaType(c.Format),
+		})
+	}
+
+	if len(np) == 0 || len(s.Config.Feeds) == 0 {
+		return alternates
+	}
+
+	primary := s.Config.Feeds[0]
+
+	// nodegroups from the innermost one, leaving out the root whose feed
+	// is the global one
+	for dir := filepath.Dir(np.String()); dir != s.InputDir && strings.HasPrefix(dir, s.InputDir); dir = filepath.Dir(dir) {
+		index := nodepath.NodePath(filepath.Join(dir, "index.md"))
+
+		md := s.NodeMap[index].Metadata
+		if !md.Feed || md.Hidden() {
+			continue
+		}
+
+		alternates = append(alternates, Alternate{
+			Title: fmt.Sprintf("%s: %s", s.Config.Title, convert.Title(md.Title, filepath.Base(dir))),
+			Href:  path.Join(s.Config.Path, NodegroupFilename(s, index)),
+			Type:  MediaType(primary.Format),
+		})
+	}
+
+	if !s.Config.TagFeeds {
+		return alternates
+	}
+
+	for _, t := range s.NodeMap[np].Metadata.Tags {
+		alternates = append(alternates, TagAlternate(s, t))
 	}
 
-	return atom, nil
+	return alternates
 }
 
-// Write outputs the RSS feed to disk
-func Write(s *state.State, atom string) error {
+// TagAlternate returns the feed of the tag name to link to, in the primary
+// feed format. Requires at least one feed to be configured.
+func TagAlternate(s *state.State, name string) Alternate {
+	return Alternate{
+		Title: fmt.Sprintf("%s: %s", s.Config.Title, name),
+		Href:  path.Join(s.Config.Path, TagFilename(s, name)),
+		Type:  MediaType(s.Config.Feeds[0].Format),
+	}
+}
+
+// TagFilename returns the filename of the feed of a tag, relative to the
+// output directory: tags/<slug> with the extension of the primary feed
+func TagFilename(s *state.State, name string) string {
+	return path.Join("tags", tag.Slug(name)+path.Ext(s.Config.Feeds[0].Filename))
+}
+
+// NodegroupFilename returns the filename of the feed of the nodegroup of the
+// index np, relative to the output directory: the primary feed filename
+// inside the nodegroup
+func NodegroupFilename(s *state.State, np nodepath.NodePath) string {
+	rel, _ := filepath.Rel(s.InputDir, filepath.Dir(np.String()))
+
+	return path.Join(filepath.ToSlash(rel), path.Base(s.Config.Feeds[0].Filename))
+}
+
+// Write outputs the feed to disk in every configured format
+func Write(s *state.State, f *Feed) error {
+	for _, c := range s.Config.Feeds {
+		if err := WriteTo(s, f, c.Format, c.Filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteTo outputs the feed to disk in the given format and filename,
+// relative to the output directory
+func WriteTo(s *state.State, f *Feed, format string, filename string) error {
+	out, err := f.Format(format)
+	if err != nil {
+		return err
+	}
+
 	if s.DryRun {
 		return nil
 	}
 
-	b := []byte(atom)
+	p := filepath.Join(s.OutputDir, filename)
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("os.MkdirAll %s: %w", filepath.Dir(p), err)
+	}
 
-	if err := os.WriteFile(s.OutputDir+"/feed.xml", b, 0o644); err != nil {
-		return fmt.Errorf("writing article: %w", err)
+	if err := os.WriteFile(p, []byte(out), 0o644); err != nil {
+		return fmt.Errorf("writing feed: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,174 @@
+package feed
+
+// Generates JSON Feed 1.1 as JSON
+// Taken relevant bits from https://github.com/gorilla/feeds/blob/main/json.go
+// and updated to https://www.jsonfeed.org/version/1.1/
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// JSONAuthor represents the author of the feed or of an individual item
+// in the feed
+type JSONAuthor struct {
+	Name   string `json:"name,omitempty"`
+	Url    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// JSONAttachment represents a related resource. Podcasts, for instance,
+// would include an attachment that's an audio or video file.
+type JSONAttachment struct {
+	Url      string `json:"url,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Size     int32  `json:"size,omitempty"`
+}
+
+// JSONItem represents a single entry/post for the feed.
+type JSONItem struct {
+	PublishedDate *time.Time       `json:"date_published,omitempty"`
+	ModifiedDate  *time.Time       `json:"date_modified,omitempty"`
+	Id            string           `json:"id"`
+	Url           string           `json:"url,omitempty"`
+	ExternalUrl   string           `json:"external_url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html,omitempty"`
+	ContentText   string           `json:"content_text,omitempty"`
+	Summary       string           `json:"summary,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	Authors       []*JSONAuthor    `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Attachments   []JSONAttachment `json:"attachments,omitempty"`
+}
+
+// JSONFeed represents a syndication feed in the JSON Feed Version 1.1 format
+type JSONFeed struct {
+	Version     string        `json:"version"`
+	Title       string        `json:"title"`
+	HomePageUrl string        `json:"home_page_url,omitempty"`
+	FeedUrl     string        `json:"feed_url,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Icon        string        `json:"icon,omitempty"`
+	Language    string        `json:"language,omitempty"`
+	Authors     []*JSONAuthor `json:"authors,omitempty"`
+	Items       []*JSONItem   `json:"items"`
+}
+
+// JSON is used to convert a generic Feed to a JSONFeed.
+type JSON struct {
+	*Feed
+}
+
+// ToJSON encodes f into a JSON string. Returns an error if marshalling fails.
+func (f *JSON) ToJSON() (string, error) {
+	return f.JSONFeed().ToJSON()
+}
+
+// ToJSON encodes f into a JSON string. Returns an error if marshalling fails.
+func (f *JSONFeed) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// JSONFeed creates a new JSONFeed with a generic Feed struct's data.
+func (f *JSON) JSONFeed() *JSONFeed {
+	feed := &JSONFeed{
+		Version:     jsonFeedVersion,
+		Title:       f.Title,
+		Description: f.Description,
+		Items:       []*JSONItem{},
+	}
+
+	if f.Link != nil {
+		feed.HomePageUrl = f.Link.Href
+	}
+
+	if f.Author != nil && (len(f.Author.Name) > 0 || len(f.Author.Email) > 0) {
+		feed.Authors = []*JSONAuthor{jsonAuthor(f.Author)}
+	}
+
+	if f.Image != nil {
+		feed.Icon = f.Image.Url
+	}
+
+	for _, e := range f.Items {
+		feed.Items = append(feed.Items, newJSONItem(e))
+	}
+
+	return feed
+}
+
+// returns the author with their email as url if they have one
+func jsonAuthor(a *Author) *JSONAuthor {
+	author := &JSONAuthor{Name: a.Name}
+	if len(a.Email) > 0 {
+		author.Url = "mailto:" + a.Email
+	}
+
+	return author
+}
+
+func newJSONItem(i *Item) *JSONItem {
+	item := &JSONItem{
+		Id:          i.Id,
+		Title:       i.Title,
+		Summary:     i.Description,
+		ContentHTML: i.Content,
+		Tags:        i.Categories,
+	}
+
+	if i.Link != nil {
+		item.Url = i.Link.Href
+	}
+
+	// the link doubles as id
+	if len(item.Id) == 0 {
+		item.Id = item.Url
+	}
+
+	if i.Source != nil {
+		item.ExternalUrl = i.Source.Href
+	}
+
+	if i.Author != nil && (len(i.Author.Name) > 0 || len(i.Author.Email) > 0) {
+		item.Authors = []*JSONAuthor{jsonAuthor(i.Author)}
+	}
+
+	if !i.Created.IsZero() {
+		item.PublishedDate = &i.Created
+	}
+
+	if !i.Updated.IsZero() {
+		item.ModifiedDate = &i.Updated
+	}
+
+	if i.Enclosure != nil {
+		item.Attachments = []JSONAttachment{{Url: i.Enclosure.Url, MIMEType: i.Enclosure.Type}}
+	}
+
+	return item
+}
+
+// creates a JSON Feed representation of this feed
+func (f *Feed) ToJSON() (string, error) {
+	j := &JSON{f}
+
+	return j.ToJSON()
+}
+
+// WriteJSON writes a JSON Feed representation of this feed to the writer.
+func (f *Feed) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	return e.Encode((&JSON{f}).JSONFeed())
+}
@@ -0,0 +1,196 @@
+package feed
+
+// Generates RSS 2.0 feed as XML
+// Taken relevant bits from https://github.com/gorilla/feeds/blob/main/rss.go
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const rssContentNs = "http://purl.org/rss/1.0/modules/content/"
+
+// private wrapper around the RssFeed which gives us the <rss>..</rss> xml
+type RssFeedXml struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	Channel          *RssFeed
+}
+
+type RssContent struct {
+	XMLName xml.Name `xml:"content:encoded"`
+	Content string   `xml:",cdata"`
+}
+
+type RssImage struct {
+	XMLName xml.Name `xml:"image"`
+	Url     string   `xml:"url"`
+	Title   string   `xml:"title"`
+	Link    string   `xml:"link"`
+	Width   int      `xml:"width,omitempty"`
+	Height  int      `xml:"height,omitempty"`
+}
+
+type RssFeed struct {
+	XMLName        xml.Name `xml:"channel"`
+	Title          string   `xml:"title"`       // required
+	Link           string   `xml:"link"`        // required
+	Description    string   `xml:"description"` // required
+	Language       string   `xml:"language,omitempty"`
+	Copyright      string   `xml:"copyright,omitempty"`
+	ManagingEditor string   `xml:"managingEditor,omitempty"` // Author used
+	PubDate        string   `xml:"pubDate,omitempty"`        // created or updated
+	LastBuildDate  string   `xml:"lastBuildDate,omitempty"`  // updated used
+	Generator      string   `xml:"generator,omitempty"`
+	Image          *RssImage
+	Items          []*RssItem `xml:"item"`
+}
+
+type RssItem struct {
+	XMLName     xml.Name `xml:"item"`
+	Title       string   `xml:"title"`       // required
+	Link        string   `xml:"link"`        // required
+	Description string   `xml:"description"` // required
+	Content     *RssContent
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category,omitempty"`
+	Enclosure   *RssEnclosure
+	Guid        *RssGuid // Id used
+	PubDate     string   `xml:"pubDate,omitempty"` // created or updated
+	Source      string   `xml:"source,omitempty"`
+}
+
+type RssEnclosure struct {
+	XMLName xml.Name `xml:"enclosure"`
+	Url     string   `xml:"url,attr"`
+	Length  string   `xml:"length,attr"`
+	Type    string   `xml:"type,attr"`
+}
+
+type RssGuid struct {
+	XMLName     xml.Name `xml:"guid"`
+	Id          string   `xml:",chardata"`
+	IsPermaLink string   `xml:"isPermaLink,attr,omitempty"`
+}
+
+type Rss struct {
+	*Feed
+}
+
+// create a new RssItem with a generic Item struct's data
+func newRssItem(i *Item) *RssItem {
+	item := &RssItem{
+		Title:       i.Title,
+		Description: i.Description,
+		PubDate:     anyTimeFormat(time.RFC1123Z, i.Created, i.Updated),
+		Categories:  i.Categories,
+	}
+
+	if i.Link != nil {
+		item.Link = i.Link.Href
+	}
+
+	// without an id, the link is a permanent guid
+	if len(i.Id) > 0 {
+		item.Guid = &RssGuid{Id: i.Id, IsPermaLink: "false"}
+	} else if len(item.Link) > 0 {
+		item.Guid = &RssGuid{Id: item.Link, IsPermaLink: "true"}
+	}
+
+	if len(i.Content) > 0 {
+		item.Content = &RssContent{Content: i.Content}
+	}
+
+	if i.Source != nil {
+		item.Source = i.Source.Href
+	}
+
+	// define a closure
+	if i.Enclosure != nil && i.Enclosure.Type != "" && i.Enclosure.Length != "" {
+		item.Enclosure = &RssEnclosure{Url: i.Enclosure.Url, Type: i.Enclosure.Type, Length: i.Enclosure.Length}
+	}
+
+	// rss authors must be email addresses
+	if i.Author != nil && len(i.Author.Email) > 0 {
+		item.Author = rssAuthor(i.Author)
+	}
+
+	return item
+}
+
+// returns the author as "email (name)", or just the email
+func rssAuthor(a *Author) string {
+	if len(a.Name) > 0 {
+		return fmt.Sprintf("%s (%s)", a.Email, a.Name)
+	}
+
+	return a.Email
+}
+
+// create a new RssFeed with a generic Feed struct's data
+func (r *Rss) RssFeed() *RssFeed {
+	pub := anyTimeFormat(time.RFC1123Z, r.Created, r.Updated)
+	build := anyTimeFormat(time.RFC1123Z, r.Updated)
+
+	author := ""
+	if r.Author != nil && len(r.Author.Email) > 0 {
+		author = rssAuthor(r.Author)
+	}
+
+	var image *RssImage
+	if r.Image != nil {
+		image = &RssImage{Url: r.Image.Url, Title: r.Image.Title, Link: r.Image.Link, Width: r.Image.Width, Height: r.Image.Height}
+	}
+
+	var href string
+	if r.Link != nil {
+		href = r.Link.Href
+	}
+
+	channel := &RssFeed{
+		Title:          r.Title,
+		Link:           href,
+		Description:    r.Description,
+		ManagingEditor: author,
+		PubDate:        pub,
+		LastBuildDate:  build,
+		Copyright:      r.Copyright,
+		Image:          image,
+	}
+
+	for _, i := range r.Items {
+		channel.Items = append(channel.Items, newRssItem(i))
+	}
+
+	return channel
+}
+
+// FeedXml returns an XML-Ready object for an Rss object
+func (r *Rss) FeedXml() interface{} {
+	// only generate version 2.0 feeds for now
+	return r.RssFeed().FeedXml()
+}
+
+// FeedXml returns an XML-ready object for an RssFeed object
+func (r *RssFeed) FeedXml() interface{} {
+	return &RssFeedXml{
+		Version:          "2.0",
+		Channel:          r,
+		ContentNamespace: rssContentNs,
+	}
+}
+
+// creates an Rss representation of this feed
+func (f *Feed) ToRss() (string, error) {
+	r := &Rss{f}
+
+	return ToXML(r)
+}
+
+// WriteRss writes an RSS representation of this feed to the writer.
+func (f *Feed) WriteRss(w io.Writer) error {
+	return WriteXML(&Rss{f}, w)
+}
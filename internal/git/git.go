@@ -0,0 +1,341 @@
+// Package git is a minimal, read-only reader for git repositories. It
+// understands just enough of the on-disk format (refs, loose objects and
+// packfiles) to resolve revisions and list the files of a commit, so pher
+// doesn't need a git binary.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when an object, ref or repository doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Hash is a SHA-1 object name.
+type Hash [20]byte
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// IsZero reports whether h is the zero hash.
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+// ParseHash parses a full hex-encoded object name.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+
+	if len(s) != 2*len(h) {
+		return h, fmt.Errorf("invalid hash %q", s)
+	}
+
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid hash %q: %w", s, err)
+	}
+
+	return h, nil
+}
+
+// BlobHash returns the object name git would give a file with contents b.
+func BlobHash(b []byte) Hash {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(b))
+	h.Write(b)
+
+	var sum Hash
+	copy(sum[:], h.Sum(nil))
+
+	return sum
+}
+
+// ObjectType is the type of a git object.
+type ObjectType int
+
+const (
+	TypeCommit   ObjectType = 1
+	TypeTree     ObjectType = 2
+	TypeBlob     ObjectType = 3
+	TypeTag      ObjectType = 4
+	typeOfsDelta ObjectType = 6
+	typeRefDelta ObjectType = 7
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case TypeCommit:
+		return "commit"
+	case TypeTree:
+		return "tree"
+	case TypeBlob:
+		return "blob"
+	case TypeTag:
+		return "tag"
+	default:
+		return fmt.Sprintf("type(%d)", int(t))
+	}
+}
+
+func parseObjectType(s string) (ObjectType, error) {
+	switch s {
+	case "commit":
+		return TypeCommit, nil
+	case "tree":
+		return TypeTree, nil
+	case "blob":
+		return TypeBlob, nil
+	case "tag":
+		return TypeTag, nil
+	default:
+		return 0, fmt.Errorf("unknown object type %q", s)
+	}
+}
+
+// Repository is a git repository on disk.
+//
+// * Root: the top level of the working tree
+type Repository struct {
+	Root string
+
+	// gitDir holds per-worktree files like HEAD, commonDir holds objects and
+	// refs. They're the same directory outside of linked worktrees.
+	gitDir    string
+	commonDir string
+
+	packs    []*pack
+	packsErr error
+	once     sync.Once
+}
+
+// Open finds the repository containing dir by walking up its parents.
+func Open(dir string) (*Repository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("filepath.Abs: %w", err)
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+
+		info, err := os.Stat(dotGit)
+		if err == nil {
+			gitDir := dotGit
+
+			// worktrees and submodules have a .git file pointing elsewhere
+			if !info.IsDir() {
+				gitDir, err = readGitFile(dotGit)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return openGitDir(dir, gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("git repository: %w", ErrNotFound)
+		}
+
+		dir = parent
+	}
+}
+
+// readGitFile reads a "gitdir: <path>" file
+func readGitFile(p string) (string, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("os.ReadFile %s: %w", p, err)
+	}
+
+	line := strings.TrimSpace(string(b))
+
+	target, ok := strings.CutPrefix(line, "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("invalid .git file %s", p)
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(p), target)
+	}
+
+	return target, nil
+}
+
+func openGitDir(root string, gitDir string) (*Repository, error) {
+	r := &Repository{Root: root, gitDir: gitDir, commonDir: gitDir}
+
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(b))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+
+		r.commonDir = commonDir
+	}
+
+	// only SHA-1 repositories are supported
+	if b, err := os.ReadFile(filepath.Join(r.commonDir, "config")); err == nil {
+		if bytes.Contains(b, []byte("objectformat = sha256")) {
+			return nil, errors.New("sha256 repositories are not supported")
+		}
+	}
+
+	return r, nil
+}
+
+// ReadObject returns the type and contents of the object named h.
+func (r *Repository) ReadObject(h Hash) (ObjectType, []byte, error) {
+	typ, data, err := r.readLoose(h)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return typ, data, err
+	}
+
+	packs, err := r.loadPacks()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, p := range packs {
+		offset, ok := p.find(h)
+		if !ok {
+			continue
+		}
+
+		return p.readAt(r, offset)
+	}
+
+	return 0, nil, fmt.Errorf("object %s: %w", h, ErrNotFound)
+}
+
+func (r *Repository) loosePath(h Hash) string {
+	s := h.String()
+
+	return filepath.Join(r.commonDir, "objects", s[:2], s[2:])
+}
+
+func (r *Repository) readLoose(h Hash) (ObjectType, []byte, error) {
+	f, err := os.Open(r.loosePath(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil, fmt.Errorf("object %s: %w", h, ErrNotFound)
+	} else if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, fmt.Errorf("object %s: %w", h, err)
+	}
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("object %s: %w", h, err)
+	}
+
+	// header is "<type> <size>\x00"
+	header, data, ok := bytes.Cut(b, []byte{0})
+	if !ok {
+		return 0, nil, fmt.Errorf("object %s: malformed header", h)
+	}
+
+	typeName, _, _ := strings.Cut(string(header), " ")
+
+	typ, err := parseObjectType(typeName)
+	if err != nil {
+		return 0, nil, fmt.Errorf("object %s: %w", h, err)
+	}
+
+	return typ, data, nil
+}
+
+func (r *Repository) loadPacks() ([]*pack, error) {
+	r.once.Do(func() {
+		idxs, err := filepath.Glob(filepath.Join(r.commonDir, "objects", "pack", "*.idx"))
+		if err != nil {
+			r.packsErr = err
+			return
+		}
+
+		for _, idx := range idxs {
+			p, err := openPack(idx)
+			if err != nil {
+				r.packsErr = err
+				return
+			}
+
+			r.packs = append(r.packs, p)
+		}
+	})
+
+	return r.packs, r.packsErr
+}
+
+// Close closes the packfiles opened by the repository. It must not be used
+// afterwards.
+func (r *Repository) Close() error {
+	var closeErrors []error
+
+	for _, p := range r.packs {
+		if err := p.file.Close(); err != nil {
+			closeErrors = append(closeErrors, err)
+		}
+	}
+
+	return errors.Join(closeErrors...)
+}
+
+// expand resolves an abbreviated object name
+func (r *Repository) expand(prefix string) (Hash, error) {
+	prefix = strings.ToLower(prefix)
+	matches := make(map[Hash]bool)
+
+	// loose objects
+	entries, _ := os.ReadDir(filepath.Join(r.commonDir, "objects", prefix[:2]))
+	for _, e := range entries {
+		name := prefix[:2] + e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if h, err := ParseHash(name); err == nil {
+			matches[h] = true
+		}
+	}
+
+	// packed objects
+	packs, err := r.loadPacks()
+	if err != nil {
+		return Hash{}, err
+	}
+
+	for _, p := range packs {
+		for _, h := range p.withPrefix(prefix) {
+			matches[h] = true
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Hash{}, fmt.Errorf("object %s: %w", prefix, ErrNotFound)
+	case 1:
+		for h := range matches {
+			return h, nil
+		}
+	}
+
+	return Hash{}, fmt.Errorf("ambiguous object name %s", prefix)
+}
@@ -0,0 +1,301 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commits of the test repository, oldest first
+var testCommits = []struct {
+	date  string
+	files map[string]string // contents, or "" to delete
+}{
+	{"2024-01-01T00:00:00Z", map[string]string{"notes/a.md": longFile("a"), "b.md": "b\n"}},
+	{"2024-02-01T00:00:00Z", map[string]string{"notes/a.md": longFile("a") + "more\n"}},
+	{"2024-03-01T00:00:00Z", map[string]string{"b.md": "", "c/d.md": "d\n"}},
+}
+
+// longFile returns contents long enough for git to store later versions as
+// deltas
+func longFile(s string) string {
+	var b strings.Builder
+	for i := range 200 {
+		b.WriteString(strings.Repeat(s, i%40) + "\n")
+	}
+
+	return b.String()
+}
+
+// run runs git in dir and returns its trimmed output
+func run(t *testing.T, dir string, env []string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// ignore the configuration of the user, e.g. signed commits
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	cmd.Env = append(cmd.Env, env...)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRepo creates a repository with testCommits on branch main, and the
+// annotated tag v1 on the second commit
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+
+	run(t, dir, nil, "init", "-q")
+	run(t, dir, nil, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	for i, c := range testCommits {
+		for name, contents := range c.files {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+
+			if len(contents) == 0 {
+				if err := os.Remove(p); err != nil {
+					t.Fatal(err)
+				}
+
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(p, []byte(contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		env := []string{
+			"GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.org", "GIT_AUTHOR_DATE=" + c.date,
+			"GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.org", "GIT_COMMITTER_DATE=" + c.date,
+		}
+
+		run(t, dir, env, "add", "-A")
+		run(t, dir, env, "commit", "-q", "-m", c.date)
+
+		if i == 1 {
+			run(t, dir, env, "tag", "-a", "-m", "v1", "v1")
+		}
+	}
+
+	return dir
+}
+
+func TestRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		// repack: arguments of git repacking the objects, none to keep
+		// them loose
+		repack []string
+		deltas bool
+	}{
+		{"loose objects", nil, false},
+		{"pack with offset deltas", []string{"repack", "-q", "-a", "-d", "-f", "--depth=10", "--window=10"}, true},
+		{"pack with ref deltas", []string{"-c", "repack.useDeltaBaseOffset=false", "repack", "-q", "-a", "-d", "-f"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+
+			if len(tt.repack) > 0 {
+				run(t, dir, nil, tt.repack...)
+				run(t, dir, nil, "pack-refs", "--all")
+			}
+
+			if tt.deltas {
+				idxs, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+				if len(idxs) != 1 {
+					t.Fatalf("got %d packs, want 1", len(idxs))
+				}
+
+				// deltified objects have a depth and a base
+				if !strings.Contains(run(t, dir, nil, "verify-pack", "-v", idxs[0]), "chain length = 1") {
+					t.Fatal("got no deltas in the pack")
+				}
+			}
+
+			repo, err := Open(filepath.Join(dir, "notes"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer repo.Close()
+
+			testResolve(t, dir, repo)
+			testFiles(t, dir, repo)
+			testHistory(t, repo)
+		})
+	}
+}
+
+func testResolve(t *testing.T, dir string, repo *Repository) {
+	head := run(t, dir, nil, "rev-parse", "HEAD")
+
+	for _, rev := range []string{"HEAD", "HEAD~1", "HEAD^", "HEAD~2", "main~1^", "refs/heads/main", "v1", head[:7], head + "~1"} {
+		want := run(t, dir, nil, "rev-parse", rev+"^{commit}")
+
+		h, err := repo.Resolve(rev)
+		if err != nil {
+			t.Errorf("resolve %s: got error %v", rev, err)
+
+			continue
+		}
+
+		if h.String() != want {
+			t.Errorf("resolve %s: got %s, want %s", rev, h, want)
+		}
+	}
+
+	for _, rev := range []string{"HEAD~3", "nope", "0000000"} {
+		if _, err := repo.Resolve(rev); err == nil {
+			t.Errorf("resolve %s: got no error", rev)
+		}
+	}
+}
+
+func testFiles(t *testing.T, dir string, repo *Repository) {
+	for _, rev := range []string{"HEAD", "HEAD~1"} {
+		h, err := repo.Resolve(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		commit, err := repo.Commit(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files, err := repo.Files(commit.Tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := make(map[string]string)
+		for line := range strings.SplitSeq(run(t, dir, nil, "ls-tree", "-r", rev), "\n") {
+			meta, name, _ := strings.Cut(line, "\t")
+			fields := strings.Fields(meta)
+			want[name] = fields[2]
+		}
+
+		if len(files) != len(want) {
+			t.Errorf("%s: got files %v, want %v", rev, files, want)
+		}
+
+		for name, blob := range files {
+			if want[name] != blob.String() {
+				t.Errorf("%s: got %s for %s, want %s", rev, blob, name, want[name])
+			}
+
+			b, err := repo.Blob(blob)
+			if err != nil {
+				t.Fatalf("%s: blob of %s: %v", rev, name, err)
+			}
+
+			if contents := run(t, dir, nil, "cat-file", "blob", blob.String()); !bytes.Equal(bytes.TrimSpace(b), []byte(contents)) {
+				t.Errorf("%s: got contents %q for %s, want %q", rev, b, name, contents)
+			}
+
+			if BlobHash(b) != blob {
+				t.Errorf("%s: got hash %s of %s, want %s", rev, BlobHash(b), name, blob)
+			}
+		}
+	}
+}
+
+func testHistory(t *testing.T, repo *Repository) {
+	head, err := repo.Resolve("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := repo.History(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := func(i int) time.Time {
+		d, _ := time.Parse(time.RFC3339, testCommits[i].date)
+
+		return d
+	}
+
+	want := map[string]FileDates{
+		"notes/a.md": {Created: date(0), Updated: date(1)},
+		"b.md":       {Created: date(0), Updated: date(2)},
+		"c/d.md":     {Created: date(2), Updated: date(2)},
+	}
+
+	if len(history) != len(want) {
+		t.Errorf("got history %v, want %v", history, want)
+	}
+
+	for name, d := range want {
+		got := history[name]
+		if !got.Created.Equal(d.Created) || !got.Updated.Equal(d.Updated) {
+			t.Errorf("%s: got %v, want %v", name, got, d)
+		}
+	}
+}
+
+func TestOpenNotFound(t *testing.T) {
+	if _, err := Open(t.TempDir()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello, world")
+
+	tests := []struct {
+		name  string
+		delta []byte
+		want  string
+		err   bool
+	}{
+		{"copy all", []byte{12, 12, 0x80 | 0x10, 12}, "hello, world", false},
+		{"copy with offset", []byte{12, 5, 0x80 | 0x01 | 0x10, 7, 5}, "world", false},
+		{"insert", []byte{12, 3, 3, 'a', 'b', 'c'}, "abc", false},
+		{"copy and insert", []byte{12, 7, 0x80 | 0x10, 5, 2, '!', '!'}, "hello!!", false},
+		{"wrong base size", []byte{11, 5, 0x80 | 0x10, 5}, "", true},
+		{"copy out of base", []byte{12, 5, 0x80 | 0x01 | 0x10, 10, 5}, "", true},
+		{"truncated insert", []byte{12, 3, 3, 'a'}, "", true},
+		{"wrong result size", []byte{12, 4, 0x80 | 0x10, 5}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyDelta(base, tt.delta)
+			if tt.err {
+				if err == nil {
+					t.Errorf("got %q, want an error", got)
+				}
+
+				return
+			}
+
+			if err != nil || string(got) != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
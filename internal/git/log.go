@@ -0,0 +1,173 @@
+package git
+
+import (
+	"errors"
+	"path"
+	"time"
+)
+
+// FileDates are the author times of the oldest and newest commits touching a
+// file.
+type FileDates struct {
+	Created time.Time
+	Updated time.Time
+}
+
+// History walks the commits reachable from the commit h and returns the dates
+// of every file they touch, keyed by slash-separated path. Each commit is
+// compared against its parent. Merge commits are skipped like git log does, so
+// files are dated by the commits that changed them. The boundary commits of
+// shallow clones touch every file they contain.
+func (r *Repository) History(h Hash) (map[string]FileDates, error) {
+	dates := make(map[string]FileDates)
+
+	seen := map[Hash]bool{h: true}
+	queue := []Hash{h}
+
+	for len(queue) > 0 {
+		c, err := r.Commit(queue[0])
+		queue = queue[1:]
+
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, p := range c.Parents {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+
+		if len(c.Parents) > 1 {
+			continue
+		}
+
+		var parentTree Hash
+
+		if len(c.Parents) == 1 {
+			parent, err := r.Commit(c.Parents[0])
+			if err == nil {
+				parentTree = parent.Tree
+			} else if !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+		}
+
+		changed, err := r.diffTrees(parentTree, c.Tree, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range changed {
+			d := dates[p]
+
+			if d.Created.IsZero() || c.AuthorTime.Before(d.Created) {
+				d.Created = c.AuthorTime
+			}
+
+			if c.AuthorTime.After(d.Updated) {
+				d.Updated = c.AuthorTime
+			}
+
+			dates[p] = d
+		}
+	}
+
+	return dates, nil
+}
+
+// diffTrees lists the files added, removed or modified between the trees a
+// and b, either of which may be the zero hash for an empty tree. Subtrees with
+// the same name aren't read.
+func (r *Repository) diffTrees(a Hash, b Hash, prefix string) ([]string, error) {
+	if a == b {
+		return nil, nil
+	}
+
+	before, err := r.treeEntries(a)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.treeEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	var changed []string
+
+	for _, name := range names {
+		ea, inA := before[name]
+		eb, inB := after[name]
+
+		if inA && inB && ea == eb {
+			continue
+		}
+
+		p := path.Join(prefix, name)
+
+		var ta, tb Hash
+
+		if inA && ea.IsTree() {
+			ta = ea.Hash
+		}
+
+		if inB && eb.IsTree() {
+			tb = eb.Hash
+		}
+
+		if !ta.IsZero() || !tb.IsZero() {
+			sub, err := r.diffTrees(ta, tb, p)
+			if err != nil {
+				return nil, err
+			}
+
+			changed = append(changed, sub...)
+		}
+
+		if (inA && isFile(ea)) || (inB && isFile(eb)) {
+			changed = append(changed, p)
+		}
+	}
+
+	return changed, nil
+}
+
+// treeEntries returns the entries of the tree h by name, none for the zero
+// hash
+func (r *Repository) treeEntries(h Hash) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+
+	if h.IsZero() {
+		return entries, nil
+	}
+
+	tree, err := r.Tree(h)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range tree {
+		entries[e.Name] = e
+	}
+
+	return entries, nil
+}
+
+func isFile(e TreeEntry) bool {
+	return !e.IsTree() && !e.IsSubmodule()
+}
@@ -0,0 +1,219 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a parsed commit object.
+type Commit struct {
+	AuthorTime time.Time
+	CommitTime time.Time
+	Parents    []Hash
+	Hash       Hash
+	Tree       Hash
+}
+
+// Commit reads and parses the commit named h.
+func (r *Repository) Commit(h Hash) (*Commit, error) {
+	typ, data, err := r.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != TypeCommit {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", h, typ)
+	}
+
+	c := &Commit{Hash: h}
+
+	// headers end at the first blank line
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+
+	for line := range strings.SplitSeq(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+
+		switch key {
+		case "tree":
+			if c.Tree, err = ParseHash(value); err != nil {
+				return nil, err
+			}
+		case "parent":
+			p, err := ParseHash(value)
+			if err != nil {
+				return nil, err
+			}
+
+			c.Parents = append(c.Parents, p)
+		case "author":
+			c.AuthorTime = parseSignatureTime(value)
+		case "committer":
+			c.CommitTime = parseSignatureTime(value)
+		}
+	}
+
+	return c, nil
+}
+
+// parseSignatureTime parses the time of "Name <email> 1700000000 +0100"
+func parseSignatureTime(sig string) time.Time {
+	fields := strings.Fields(sig[strings.LastIndexByte(sig, '>')+1:])
+	if len(fields) != 2 {
+		return time.Time{}
+	}
+
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	t := time.Unix(secs, 0)
+
+	// keep the author's offset, e.g. +0100
+	tz := fields[1]
+	if len(tz) == 5 {
+		hours, errH := strconv.Atoi(tz[1:3])
+		mins, errM := strconv.Atoi(tz[3:5])
+
+		if errH == nil && errM == nil {
+			offset := hours*3600 + mins*60
+			if tz[0] == '-' {
+				offset = -offset
+			}
+
+			t = t.In(time.FixedZone(tz, offset))
+		}
+	}
+
+	return t
+}
+
+// TreeEntry is a single entry of a tree object.
+type TreeEntry struct {
+	Name string
+	Mode string
+	Hash Hash
+}
+
+// IsTree reports whether the entry is a subdirectory.
+func (e TreeEntry) IsTree() bool {
+	return e.Mode == "40000"
+}
+
+// IsSubmodule reports whether the entry is a submodule commit.
+func (e TreeEntry) IsSubmodule() bool {
+	return e.Mode == "160000"
+}
+
+// Tree reads and parses the tree named h.
+func (r *Repository) Tree(h Hash) ([]TreeEntry, error) {
+	typ, data, err := r.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != TypeTree {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", h, typ)
+	}
+
+	var entries []TreeEntry
+
+	// each entry is "<mode> <name>\x00<20-byte hash>"
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+
+		if sp < 0 || nul < sp || len(data) < nul+1+20 {
+			return nil, fmt.Errorf("malformed tree %s", h)
+		}
+
+		e := TreeEntry{Mode: string(data[:sp]), Name: string(data[sp+1 : nul])}
+		copy(e.Hash[:], data[nul+1:nul+21])
+		entries = append(entries, e)
+
+		data = data[nul+21:]
+	}
+
+	return entries, nil
+}
+
+// Files recursively lists the blobs of the tree named h, keyed by their
+// slash-separated path.
+func (r *Repository) Files(h Hash) (map[string]Hash, error) {
+	files := make(map[string]Hash)
+
+	if err := r.walkTree(h, "", files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (r *Repository) walkTree(h Hash, prefix string, files map[string]Hash) error {
+	entries, err := r.Tree(h)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		p := path.Join(prefix, e.Name)
+
+		switch {
+		case e.IsSubmodule():
+			continue
+		case e.IsTree():
+			if err := r.walkTree(e.Hash, p, files); err != nil {
+				return err
+			}
+		default:
+			files[p] = e.Hash
+		}
+	}
+
+	return nil
+}
+
+// Blob reads the contents of the blob named h.
+func (r *Repository) Blob(h Hash) ([]byte, error) {
+	typ, data, err := r.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != TypeBlob {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", h, typ)
+	}
+
+	return data, nil
+}
+
+// peel follows annotated tags until a non-tag object is reached
+func (r *Repository) peel(h Hash) (Hash, error) {
+	for range 16 {
+		typ, data, err := r.ReadObject(h)
+		if err != nil {
+			return Hash{}, err
+		}
+
+		if typ != TypeTag {
+			return h, nil
+		}
+
+		target, _, _ := bytes.Cut(data, []byte("\n"))
+
+		value, ok := bytes.CutPrefix(target, []byte("object "))
+		if !ok {
+			return Hash{}, fmt.Errorf("malformed tag %s", h)
+		}
+
+		if h, err = ParseHash(string(value)); err != nil {
+			return Hash{}, err
+		}
+	}
+
+	return Hash{}, fmt.Errorf("tag chain too deep at %s", h)
+}
@@ -0,0 +1,343 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var idxMagic = []byte{0xff, 't', 'O', 'c'}
+
+// maxCachedBases bounds the number of delta bases kept in memory per pack
+const maxCachedBases = 256
+
+type cachedObject struct {
+	data []byte
+	typ  ObjectType
+}
+
+// pack is a packfile and its version 2 index.
+type pack struct {
+	file *os.File
+	// bases caches decoded objects by offset, since chains of deltas
+	// often share bases
+	bases        map[int64]cachedObject
+	hashes       []byte // sorted 20-byte object names
+	offsets      []byte // 4-byte offsets
+	largeOffsets []byte // 8-byte offsets
+	fanout       [256]uint32
+	mu           sync.Mutex
+}
+
+func openPack(idxPath string) (*pack, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile %s: %w", idxPath, err)
+	}
+
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], idxMagic) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("unsupported pack index %s", idxPath)
+	}
+
+	p := &pack{bases: make(map[int64]cachedObject)}
+
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+
+	if len(idx) < pos+n*(20+4+4) {
+		return nil, fmt.Errorf("truncated pack index %s", idxPath)
+	}
+
+	p.hashes = idx[pos : pos+20*n]
+	pos += 20 * n
+	pos += 4 * n // crc32s
+	p.offsets = idx[pos : pos+4*n]
+	pos += 4 * n
+	p.largeOffsets = idx[pos:]
+
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+
+	p.file, err = os.Open(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open %s: %w", packPath, err)
+	}
+
+	return p, nil
+}
+
+func (p *pack) hashAt(i int) []byte {
+	return p.hashes[20*i : 20*i+20]
+}
+
+func (p *pack) offsetAt(i int) int64 {
+	off := binary.BigEndian.Uint32(p.offsets[4*i:])
+	if off&0x80000000 == 0 {
+		return int64(off)
+	}
+
+	j := int(off & 0x7fffffff)
+
+	return int64(binary.BigEndian.Uint64(p.largeOffsets[8*j:]))
+}
+
+// find returns the offset of h in the pack
+func (p *pack) find(h Hash) (int64, bool) {
+	lo := 0
+	if h[0] > 0 {
+		lo = int(p.fanout[h[0]-1])
+	}
+
+	hi := int(p.fanout[h[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashAt(lo+i), h[:]) >= 0
+	})
+
+	if i < hi && bytes.Equal(p.hashAt(i), h[:]) {
+		return p.offsetAt(i), true
+	}
+
+	return 0, false
+}
+
+// withPrefix returns all object names in the pack starting with the hex
+// prefix
+func (p *pack) withPrefix(prefix string) []Hash {
+	var matches []Hash
+
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+
+	lo := 0
+	if first[0] > 0 {
+		lo = int(p.fanout[first[0]-1])
+	}
+
+	for i := lo; i < int(p.fanout[first[0]]); i++ {
+		name := hex.EncodeToString(p.hashAt(i))
+		if strings.HasPrefix(name, prefix) {
+			var h Hash
+			copy(h[:], p.hashAt(i))
+			matches = append(matches, h)
+		}
+	}
+
+	return matches
+}
+
+// readAt decodes the object at offset, resolving deltas
+func (p *pack) readAt(r *Repository, offset int64) (ObjectType, []byte, error) {
+	p.mu.Lock()
+	cached, ok := p.bases[offset]
+	p.mu.Unlock()
+
+	if ok {
+		return cached.typ, cached.data, nil
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	// object header: type in bits 4-6 of the first byte, size in a
+	// little-endian varint
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	typ := ObjectType((c >> 4) & 0x7)
+	size := int64(c & 0x0f)
+
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+
+		size |= int64(c&0x7f) << shift
+	}
+
+	var (
+		baseType ObjectType
+		base     []byte
+	)
+
+	switch typ {
+	case TypeCommit, TypeTree, TypeBlob, TypeTag:
+	case typeOfsDelta:
+		// base offset is relative to this object, in a big-endian
+		// varint where each continuation adds one
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+
+		baseType, base, err = p.readAt(r, offset-rel)
+		if err != nil {
+			return 0, nil, err
+		}
+	case typeRefDelta:
+		var h Hash
+		if _, err := io.ReadFull(br, h[:]); err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err = r.ReadObject(h)
+		if err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("unknown packed object type %d at %d", typ, offset)
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, nil, fmt.Errorf("packed object at %d: %w", offset, err)
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return 0, nil, fmt.Errorf("packed object at %d: %w", offset, err)
+	}
+
+	if base != nil {
+		typ = baseType
+
+		data, err = applyDelta(base, data)
+		if err != nil {
+			return 0, nil, fmt.Errorf("packed object at %d: %w", offset, err)
+		}
+	}
+
+	p.mu.Lock()
+	if len(p.bases) >= maxCachedBases {
+		clear(p.bases)
+	}
+	p.bases[offset] = cachedObject{typ: typ, data: data}
+	p.mu.Unlock()
+
+	return typ, data, nil
+}
+
+var errBadDelta = errors.New("malformed delta")
+
+// applyDelta reconstructs an object from its base and a delta
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	readSize := func() (int, error) {
+		size, shift := 0, 0
+
+		for {
+			if len(delta) == 0 {
+				return 0, errBadDelta
+			}
+
+			c := delta[0]
+			delta = delta[1:]
+			size |= int(c&0x7f) << shift
+			shift += 7
+
+			if c&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+
+	srcSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if srcSize != len(base) {
+		return nil, errBadDelta
+	}
+
+	dstSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, dstSize)
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			// copy from base: bits 0-3 select offset bytes, bits 4-6
+			// select size bytes
+			var offset, size int
+
+			for i := range 4 {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errBadDelta
+					}
+
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+
+			for i := range 3 {
+				if op&(1<<(4+i)) != 0 {
+					if len(delta) == 0 {
+						return nil, errBadDelta
+					}
+
+					size |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+
+			if size == 0 {
+				size = 0x10000
+			}
+
+			if offset+size > len(base) {
+				return nil, errBadDelta
+			}
+
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			// insert the next op bytes
+			if int(op) > len(delta) {
+				return nil, errBadDelta
+			}
+
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errBadDelta
+		}
+	}
+
+	if len(out) != dstSize {
+		return nil, errBadDelta
+	}
+
+	return out, nil
+}
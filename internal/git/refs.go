@@ -0,0 +1,215 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Resolve resolves a revision to a commit. Supported forms are full and
+// abbreviated object names, HEAD, branch, tag and remote names (short or
+// fully qualified), each optionally followed by any number of ~<n> and ^<n>
+// suffixes.
+func (r *Repository) Resolve(rev string) (Hash, error) {
+	base := rev
+	suffixStart := strings.IndexAny(rev, "~^")
+
+	if suffixStart >= 0 {
+		base = rev[:suffixStart]
+	}
+
+	h, err := r.resolveBase(base)
+	if err != nil {
+		return Hash{}, fmt.Errorf("resolve %s: %w", rev, err)
+	}
+
+	h, err = r.peel(h)
+	if err != nil {
+		return Hash{}, fmt.Errorf("resolve %s: %w", rev, err)
+	}
+
+	if suffixStart < 0 {
+		return h, nil
+	}
+
+	// walk ancestry suffixes from left to right
+	suffix := rev[suffixStart:]
+	for len(suffix) > 0 {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[:digits])
+			suffix = suffix[digits:]
+		}
+
+		switch op {
+		case '~':
+			for range n {
+				if h, err = r.parent(h, 1); err != nil {
+					return Hash{}, fmt.Errorf("resolve %s: %w", rev, err)
+				}
+			}
+		case '^':
+			if n == 0 {
+				continue
+			}
+
+			if h, err = r.parent(h, n); err != nil {
+				return Hash{}, fmt.Errorf("resolve %s: %w", rev, err)
+			}
+		default:
+			return Hash{}, fmt.Errorf("resolve %s: unsupported revision syntax", rev)
+		}
+	}
+
+	return h, nil
+}
+
+// parent returns the nth (1-based) parent of commit h
+func (r *Repository) parent(h Hash, n int) (Hash, error) {
+	c, err := r.Commit(h)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if n > len(c.Parents) {
+		return Hash{}, fmt.Errorf("commit %s has no parent %d", h, n)
+	}
+
+	return c.Parents[n-1], nil
+}
+
+func (r *Repository) resolveBase(name string) (Hash, error) {
+	if len(name) == 0 {
+		name = "HEAD"
+	}
+
+	// refs take precedence, as in git
+	candidates := []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	}
+
+	for _, ref := range candidates {
+		h, err := r.readRef(ref, 0)
+		if err == nil {
+			return h, nil
+		} else if !errors.Is(err, ErrNotFound) {
+			return Hash{}, err
+		}
+	}
+
+	if len(name) == 40 {
+		if h, err := ParseHash(name); err == nil {
+			return h, nil
+		}
+	}
+
+	if len(name) >= 4 && isHex(name) {
+		return r.expand(name)
+	}
+
+	return Hash{}, fmt.Errorf("revision %s: %w", name, ErrNotFound)
+}
+
+// readRef reads a loose or packed ref, following symbolic refs
+func (r *Repository) readRef(name string, depth int) (Hash, error) {
+	if depth > 8 {
+		return Hash{}, fmt.Errorf("ref %s: too many levels of symbolic refs", name)
+	}
+
+	// HEAD and other pseudo refs are per worktree
+	dirs := []string{r.commonDir}
+	if !strings.HasPrefix(name, "refs/") {
+		if !isPseudoRef(name) {
+			return Hash{}, fmt.Errorf("ref %s: %w", name, ErrNotFound)
+		}
+
+		dirs = []string{r.gitDir}
+	}
+
+	for _, dir := range dirs {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+
+		content := strings.TrimSpace(string(b))
+
+		if target, ok := strings.CutPrefix(content, "ref: "); ok {
+			return r.readRef(target, depth+1)
+		}
+
+		// FETCH_HEAD and friends may carry extra fields
+		hash, _, _ := strings.Cut(content, "\t")
+
+		return ParseHash(strings.TrimSpace(hash))
+	}
+
+	return r.readPackedRef(name)
+}
+
+func (r *Repository) readPackedRef(name string) (Hash, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Hash{}, fmt.Errorf("ref %s: %w", name, ErrNotFound)
+	} else if err != nil {
+		return Hash{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+
+		hash, ref, ok := strings.Cut(line, " ")
+		if ok && ref == name {
+			return ParseHash(hash)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Hash{}, err
+	}
+
+	return Hash{}, fmt.Errorf("ref %s: %w", name, ErrNotFound)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPseudoRef reports whether name looks like HEAD, ORIG_HEAD, etc.
+func isPseudoRef(name string) bool {
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+
+	return len(name) > 0
+}
@@ -0,0 +1,383 @@
+// Package graph exports the graph of pages, assets and tags linked together
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/source"
+	"github.com/mstcl/pher/v3/internal/state"
+	"github.com/mstcl/pher/v3/internal/tag"
+)
+
+var Logger *slog.Logger
+
+const (
+	// Filename is the graph in JSON, read by the graph page
+	Filename = "graph.json"
+
+	dotFilename     = "graph.dot"
+	graphMLFilename = "graph.graphml"
+)
+
+// Types of nodes and edges
+const (
+	TypePage     = "page"
+	TypeAsset    = "asset"
+	TypeTag      = "tag"
+	TypeWikilink = "wikilink"
+	TypeImage    = "image"
+)
+
+// Graph is the graph of the site.
+//
+// * Nodes: pages, linked assets and tags, ordered by id
+//
+// * Edges: wikilinks and images from pages to pages or assets, and tags from
+// pages to tags, ordered by source then target
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node is a node of the graph.
+//
+// * ID: href of pages without extension, path of assets relative to the
+// input directory, "tag:" followed by the name of tags
+//
+// * Href: link to the node relative to the site path
+type Node struct {
+	ID    string   `json:"id"`
+	Type  string   `json:"type"`
+	Title string   `json:"title"`
+	Href  string   `json:"href"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// Edge is a directed edge of the graph
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// Construct builds the graph from the links of all included sources, resolved
+// the same way as extractExtras(). Links to missing pages, pages left out and
+// remote images are left out.
+func Construct(s *state.State) *Graph {
+	nodes := make(map[string]Node)
+	edges := make(map[Edge]bool)
+
+	ext := ""
+	if s.Config.IsExt {
+		ext = ".html"
+	}
+
+	for _, np := range s.NodePaths {
+		entry := s.NodeMap[np]
+		if !Included(s, np) {
+			continue
+		}
+
+		id := ID(s, np)
+
+		// Nodes in log nodegroups are only rendered as part of their
+		// nodegroup index, so point there instead
+		target := np
+		if s.SkippedNodePathMap[np] {
+			target = nodepath.NodePath(filepath.Join(filepath.Dir(np.String()), "index.md"))
+		}
+
+		// Untitled nodegroup indexes are named after their directory
+		fallback := np.Base()
+		if fallback == "index" && np != nodepath.NodePath(filepath.Join(s.InputDir, "index.md")) {
+			fallback = filepath.Base(filepath.Dir(np.String()))
+		}
+
+		nodes[id] = Node{
+			ID:    id,
+			Type:  TypePage,
+			Title: convert.Title(entry.Metadata.Title, fallback),
+			Href:  target.Href(s.InputDir, false) + ext,
+			Tags:  entry.Metadata.Tags,
+		}
+
+		dir := filepath.Dir(np.String())
+
+		for _, ref := range entry.Links.Refs {
+			switch ref.Kind {
+			case source.RefImage:
+				if u, err := url.Parse(ref.Target); err != nil || len(u.Scheme) > 0 || len(u.Host) > 0 {
+					continue
+				}
+
+				asset := addAsset(s, nodes, filepath.Join(dir, ref.Target))
+				edges[Edge{Source: id, Target: asset, Type: TypeImage}] = true
+			case source.RefWikilink:
+				if len(ref.Target) == 0 {
+					continue
+				}
+
+				// links with extensions are assets, relative to the source
+				if len(filepath.Ext(ref.Target)) > 0 {
+					asset := addAsset(s, nodes, filepath.Join(dir, ref.Target))
+					edges[Edge{Source: id, Target: asset, Type: TypeImage}] = true
+
+					continue
+				}
+
+				linked, _ := s.Resolver.Resolve(np, ref.Target)
+				if len(linked) == 0 || !Included(s, linked) {
+					continue
+				}
+
+				edges[Edge{Source: id, Target: ID(s, linked), Type: TypeWikilink}] = true
+			}
+		}
+
+		for _, t := range entry.Metadata.Tags {
+			tagID := "tag:" + t
+			nodes[tagID] = Node{
+				ID:    tagID,
+				Type:  TypeTag,
+				Title: "#" + t,
+				Href:  tag.Href(t, s.Config.IsExt),
+			}
+			edges[Edge{Source: id, Target: tagID, Type: TypeTag}] = true
+		}
+
+		Logger.Debug("added node to graph", slog.Any("nodepath", np), slog.String("context", "graph"))
+	}
+
+	g := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+
+	for e := range edges {
+		g.Edges = append(g.Edges, e)
+	}
+
+	sort.SliceStable(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Source != g.Edges[j].Source {
+			return g.Edges[i].Source < g.Edges[j].Source
+		}
+
+		if g.Edges[i].Target != g.Edges[j].Target {
+			return g.Edges[i].Target < g.Edges[j].Target
+		}
+
+		return g.Edges[i].Type < g.Edges[j].Type
+	})
+
+	return g
+}
+
+// Included reports whether the page of np is a node of the graph: drafts and
+// unpublished pages are left out, and unlisted pages unless
+// graph.includeUnlisted is set
+func Included(s *state.State, np nodepath.NodePath) bool {
+	md := s.NodeMap[np].Metadata
+
+	return !md.Hidden() && (!md.Unlisted || s.Config.Graph.IncludeUnlisted)
+}
+
+// ID returns the id of the page node of np
+func ID(s *state.State, np nodepath.NodePath) string {
+	return filepath.ToSlash(np.Href(s.InputDir, false))
+}
+
+// addAsset adds the asset at p to nodes, returning its id
+func addAsset(s *state.State, nodes map[string]Node, p string) string {
+	rel, err := filepath.Rel(s.InputDir, p)
+	if err != nil {
+		rel = p
+	}
+
+	id := filepath.ToSlash(rel)
+	nodes[id] = Node{
+		ID:    id,
+		Type:  TypeAsset,
+		Title: path.Base(id),
+		Href:  id,
+	}
+
+	return id
+}
+
+// Write outputs the graph as JSON, and as DOT and GraphML if enabled
+func Write(s *state.State, g *Graph) error {
+	if s.DryRun {
+		return nil
+	}
+
+	if err := writeFile(s, Filename, func(w *bufio.Writer) error {
+		return json.NewEncoder(w).Encode(g)
+	}); err != nil {
+		return err
+	}
+
+	if s.Config.Graph.Dot {
+		if err := writeFile(s, dotFilename, func(w *bufio.Writer) error {
+			return writeDot(w, g)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if s.Config.Graph.GraphML {
+		if err := writeFile(s, graphMLFilename, func(w *bufio.Writer) error {
+			return writeGraphML(w, g)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDot writes g in the DOT language of Graphviz
+func writeDot(w *bufio.Writer, g *Graph) error {
+	if _, err := fmt.Fprintln(w, "digraph pher {"); err != nil {
+		return err
+	}
+
+	for _, n := range g.Nodes {
+		if _, err := fmt.Fprintf(w, "  %s [label=%s, type=%s, href=%s];\n",
+			strconv.Quote(n.ID), strconv.Quote(n.Title), strconv.Quote(n.Type), strconv.Quote(n.Href)); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range g.Edges {
+		if _, err := fmt.Fprintf(w, "  %s -> %s [type=%s];\n",
+			strconv.Quote(e.Source), strconv.Quote(e.Target), strconv.Quote(e.Type)); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// writeGraphML writes g as GraphML
+func writeGraphML(w *bufio.Writer, g *Graph) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "title", For: "node", AttrName: "title", AttrType: "string"},
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "href", For: "node", AttrName: "href", AttrType: "string"},
+			{ID: "etype", For: "edge", AttrName: "type", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "pher", EdgeDefault: "directed"},
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.ID,
+			Data: []graphMLData{
+				{Key: "title", Value: n.Title},
+				{Key: "type", Value: n.Type},
+				{Key: "href", Value: n.Href},
+			},
+		})
+	}
+
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.Source,
+			Target: e.Target,
+			Data:   []graphMLData{{Key: "etype", Value: e.Type}},
+		})
+	}
+
+	if _, err := w.WriteString(xml.Header); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+
+	if err := e.Encode(doc); err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+// writeFile creates filename in the output directory and writes to it with
+// write
+func writeFile(s *state.State, filename string, write func(w *bufio.Writer) error) error {
+	f, err := os.Create(filepath.Join(s.OutputDir, filename))
+	if err != nil {
+		return fmt.Errorf("os.Create %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	if err := write(w); err != nil {
+		return fmt.Errorf("writing %s: %w", filename, err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing %s: %w", filename, err)
+	}
+
+	return nil
+}
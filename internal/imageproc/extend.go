@@ -0,0 +1,83 @@
+package imageproc
+
+import (
+	"strconv"
+
+	"github.com/mstcl/pher/v3/internal/wikilink"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Extender extends a goldmark Markdown object so that local images, linked
+// with ![]() or embedded with ![[...]], carry width, height, srcset and sizes
+// attributes.
+type Extender struct {
+	Transformer *Transformer
+}
+
+// Extend adds the Transformer to the provided Markdown parser.
+func (e *Extender) Extend(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(e.Transformer, 100),
+		),
+	)
+}
+
+// Transformer is a goldmark AST transformer that sets responsive attributes
+// on images.
+//
+// * Dir: directory of the source file, which image links are relative to
+//
+// * Found: absolute paths of the images found, populated on Transform
+type Transformer struct {
+	Pipeline *Pipeline
+	Dir      string
+	Found    []string
+}
+
+var _ parser.ASTTransformer = (*Transformer)(nil) // interface compliance
+
+// Transform sets attributes on all local images of the document.
+func (t *Transformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var dest string
+
+		switch n := n.(type) {
+		case *ast.Image:
+			dest = string(n.Destination)
+		case *wikilink.Node:
+			if !n.Embed {
+				return ast.WalkContinue, nil
+			}
+
+			dest = string(n.Target)
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		attrs, imagePath, ok := t.Pipeline.Attributes(t.Dir, dest)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		t.Found = append(t.Found, imagePath)
+
+		n.SetAttributeString("width", []byte(strconv.Itoa(attrs.Width)))
+		n.SetAttributeString("height", []byte(strconv.Itoa(attrs.Height)))
+
+		if len(attrs.Srcset) > 0 {
+			n.SetAttributeString("srcset", []byte(attrs.Srcset))
+			n.SetAttributeString("sizes", []byte(attrs.Sizes))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,228 @@
+// Package imageproc resizes and re-encodes images linked from sources, and
+// computes the responsive attributes of their <img> tags.
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	// register gif for probing only, gifs are copied as is
+	_ "image/gif"
+
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/image/draw"
+)
+
+// Pipeline holds the image processing options.
+//
+// * Widths: widths of the resized variants, only those smaller than the
+// original are generated
+//
+// * Quality: JPEG quality (1-100)
+//
+// * Sizes: value of the sizes attribute on <img> tags
+type Pipeline struct {
+	Sizes   string
+	Widths  []int
+	Quality int
+}
+
+// Attributes are the responsive attributes of an <img> tag
+type Attributes struct {
+	Srcset string
+	Sizes  string
+	Width  int
+	Height int
+}
+
+// Supported reports whether the image at p is resized and re-encoded.
+func Supported(p string) bool {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	default:
+		return false
+	}
+}
+
+// VariantPath returns the path (or URL path) of the variant of p resized to
+// width, e.g. a/b.jpg -> a/b-480w.jpg
+func VariantPath(p string, width int) string {
+	ext := filepath.Ext(p)
+
+	return strings.TrimSuffix(p, ext) + "-" + strconv.Itoa(width) + "w" + ext
+}
+
+// variantWidths returns the configured widths smaller than the original width
+func (p *Pipeline) variantWidths(width int) []int {
+	var widths []int
+
+	for _, w := range p.Widths {
+		if w > 0 && w < width && !slices.Contains(widths, w) {
+			widths = append(widths, w)
+		}
+	}
+
+	slices.Sort(widths)
+
+	return widths
+}
+
+// probe returns the dimensions of the image at p without decoding it fully
+func probe(p string) (image.Config, string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer f.Close()
+
+	return image.DecodeConfig(f)
+}
+
+// Variants returns the paths of the resized variants of the image at p
+func (p *Pipeline) Variants(imagePath string) ([]string, error) {
+	if !Supported(imagePath) {
+		return nil, nil
+	}
+
+	cfg, _, err := probe(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("probe %s: %w", imagePath, err)
+	}
+
+	var paths []string
+	for _, w := range p.variantWidths(cfg.Width) {
+		paths = append(paths, VariantPath(imagePath, w))
+	}
+
+	return paths, nil
+}
+
+// Attributes computes the <img> attributes of dest, the link to an image as
+// written in a source file in dir. It returns the absolute path of the image
+// and false if dest isn't a local image.
+func (p *Pipeline) Attributes(dir string, dest string) (*Attributes, string, bool) {
+	if u, err := url.Parse(dest); err != nil || u.Scheme != "" || u.Host != "" {
+		return nil, "", false
+	}
+
+	unescaped, err := url.PathUnescape(dest)
+	if err != nil {
+		return nil, "", false
+	}
+
+	imagePath, err := filepath.Abs(filepath.Join(dir, unescaped))
+	if err != nil {
+		return nil, "", false
+	}
+
+	cfg, _, err := probe(imagePath)
+	if err != nil {
+		return nil, "", false
+	}
+
+	attrs := &Attributes{Width: cfg.Width, Height: cfg.Height}
+
+	if !Supported(imagePath) {
+		return attrs, imagePath, true
+	}
+
+	widths := p.variantWidths(cfg.Width)
+	if len(widths) == 0 {
+		return attrs, imagePath, true
+	}
+
+	var srcset []string
+	for _, w := range widths {
+		srcset = append(srcset, fmt.Sprintf("%s %dw", srcsetURL(VariantPath(dest, w)), w))
+	}
+
+	srcset = append(srcset, fmt.Sprintf("%s %dw", srcsetURL(dest), cfg.Width))
+
+	attrs.Srcset = strings.Join(srcset, ", ")
+	attrs.Sizes = p.Sizes
+
+	return attrs, imagePath, true
+}
+
+// srcsetURL escapes dest like the src of images, and its commas, which would
+// otherwise split the candidate
+func srcsetURL(dest string) string {
+	return strings.ReplaceAll(string(util.URLEscape([]byte(dest), true)), ",", "%2C")
+}
+
+// Process re-encodes the image at inPath to outPath and writes its resized
+// variants next to it. It returns the paths of the variants written.
+func (p *Pipeline) Process(inPath string, outPath string) ([]string, error) {
+	b, err := os.ReadFile(inPath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile %s: %w", inPath, err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", inPath, err)
+	}
+
+	// keep the original if re-encoding doesn't make it smaller
+	encoded := new(bytes.Buffer)
+	if err := p.encode(encoded, img, format); err != nil {
+		return nil, fmt.Errorf("encode %s: %w", inPath, err)
+	}
+
+	if encoded.Len() > len(b) {
+		encoded = bytes.NewBuffer(b)
+	}
+
+	if err := os.WriteFile(outPath, encoded.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("os.WriteFile %s: %w", outPath, err)
+	}
+
+	var variants []string
+
+	bounds := img.Bounds()
+
+	for _, w := range p.variantWidths(bounds.Dx()) {
+		h := max(1, bounds.Dy()*w/bounds.Dx())
+
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+		variantPath := VariantPath(outPath, w)
+
+		resized := new(bytes.Buffer)
+		if err := p.encode(resized, dst, format); err != nil {
+			return nil, fmt.Errorf("encode %s: %w", variantPath, err)
+		}
+
+		if err := os.WriteFile(variantPath, resized.Bytes(), 0o644); err != nil {
+			return nil, fmt.Errorf("os.WriteFile %s: %w", variantPath, err)
+		}
+
+		variants = append(variants, variantPath)
+	}
+
+	return variants, nil
+}
+
+func (p *Pipeline) encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: p.Quality})
+	case "png":
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		return enc.Encode(w, img)
+	default:
+		return fmt.Errorf("unsupported format %s", format)
+	}
+}
@@ -0,0 +1,82 @@
+// Package livereload pushes reload events to browsers over server-sent events
+package livereload
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Endpoint is where browsers subscribe to reload events
+const Endpoint = "/_pher/livereload"
+
+// Broker keeps track of connected browsers and notifies them of reloads.
+type Broker struct {
+	clients map[chan struct{}]struct{}
+	mu      sync.Mutex
+}
+
+func New() *Broker {
+	return &Broker{clients: make(map[chan struct{}]struct{})}
+}
+
+// Reload tells all connected browsers to reload the page.
+func (b *Broker) Reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.clients {
+		// drop the event if the client already has one pending
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *Broker) subscribe() chan struct{} {
+	c := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+
+	return c
+}
+
+func (b *Broker) unsubscribe(c chan struct{}) {
+	b.mu.Lock()
+	delete(b.clients, c)
+	b.mu.Unlock()
+}
+
+// ServeHTTP holds the connection open and streams reload events until the
+// client goes away.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	c := b.subscribe()
+	defer b.unsubscribe(c)
+
+	// send a comment so the browser knows the stream is open
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
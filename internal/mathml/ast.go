@@ -0,0 +1,66 @@
+package mathml
+
+import (
+	"github.com/yuin/goldmark/ast"
+)
+
+// KindInline is the kind of inline math AST nodes.
+var KindInline = ast.NewNodeKind("InlineMath")
+
+// KindBlock is the kind of display math AST nodes.
+var KindBlock = ast.NewNodeKind("DisplayMath")
+
+// Inline is an inline math AST node, $...$, or $$...$$ within a paragraph
+// for display math.
+type Inline struct {
+	ast.BaseInline
+
+	// TeX source, without delimiters
+	TeX []byte
+
+	// Whether the math is delimited by $$
+	Display bool
+
+	// offset of the TeX source in the document
+	start int
+}
+
+var _ ast.Node = (*Inline)(nil)
+
+// Kind reports the kind of this node.
+func (n *Inline) Kind() ast.NodeKind {
+	return KindInline
+}
+
+// Dump dumps the Node to stdout.
+func (n *Inline) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{
+		"TeX": string(n.TeX),
+	}, nil)
+}
+
+// Block is a display math AST node, a block delimited by $$ lines. Its lines
+// hold the TeX source.
+type Block struct {
+	ast.BaseBlock
+
+	// whether the closing $$ has been found
+	closed bool
+}
+
+var _ ast.Node = (*Block)(nil)
+
+// Kind reports the kind of this node.
+func (n *Block) Kind() ast.NodeKind {
+	return KindBlock
+}
+
+// IsRaw reports that the lines of the block aren't markdown.
+func (n *Block) IsRaw() bool {
+	return true
+}
+
+// Dump dumps the Node to stdout.
+func (n *Block) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, nil, nil)
+}
@@ -0,0 +1,3 @@
+// Package mathml adds $...$ (inline) and $$...$$ (display) TeX math to the
+// goldmark Markdown parser, rendered to MathML at build time.
+package mathml
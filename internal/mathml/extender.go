@@ -0,0 +1,40 @@
+package mathml
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Extender extends a goldmark Markdown object with support for parsing TeX
+// math and rendering it as MathML.
+//
+// * Renderer: renders the math, and collects unsupported commands. A new
+// Renderer is used if nil.
+type Extender struct {
+	Renderer *Renderer
+}
+
+// Extend extends the provided Markdown object with support for math.
+func (e *Extender) Extend(md goldmark.Markdown) {
+	if e.Renderer == nil {
+		e.Renderer = &Renderer{}
+	}
+
+	// Take precedence over emphasis, so that $a_b_c$ stays math
+	md.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(&InlineParser{}, 150),
+		),
+		parser.WithBlockParsers(
+			util.Prioritized(&BlockParser{}, 150),
+		),
+	)
+
+	md.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(e.Renderer, 150),
+		),
+	)
+}
@@ -0,0 +1,160 @@
+package mathml
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var _delim = []byte("$$")
+
+// InlineParser parses $...$ and $$...$$ within a line.
+type InlineParser struct{}
+
+var _ parser.InlineParser = (*InlineParser)(nil)
+
+// Trigger returns characters that trigger this parser.
+func (p *InlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse parses inline math. Like pandoc, the opening $ must not be followed
+// by a space, and the closing $ must not be preceded by a space nor followed
+// by a digit, so that amounts such as $5 and $10 are left alone. Inline math
+// can't contain $$.
+func (p *InlineParser) Parse(_ ast.Node, block text.Reader, _ parser.Context) ast.Node {
+	line, seg := block.PeekLine()
+
+	if bytes.HasPrefix(line, _delim) {
+		stop := bytes.Index(line[2:], _delim)
+		if stop <= 0 {
+			return nil // must close on the same line
+		}
+
+		block.Advance(stop + 4)
+
+		tex := text.NewSegment(seg.Start+2, seg.Start+2+stop)
+
+		return &Inline{TeX: block.Value(tex), Display: true, start: tex.Start}
+	}
+
+	if len(line) < 3 || util.IsSpace(line[1]) || line[1] == '$' {
+		return nil
+	}
+
+	for i := 2; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '$':
+			// inline math doesn't span display math
+			if i+1 < len(line) && line[i+1] == '$' {
+				return nil
+			}
+
+			if util.IsSpace(line[i-1]) || (i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9') {
+				continue
+			}
+
+			block.Advance(i + 1)
+
+			tex := text.NewSegment(seg.Start+1, seg.Start+i)
+
+			return &Inline{TeX: block.Value(tex), start: tex.Start}
+		}
+	}
+
+	return nil
+}
+
+// BlockParser parses display math in blocks delimited by $$ lines:
+//
+//	$$
+//	x^2
+//	$$
+//
+// or on a single line, $$x^2$$.
+type BlockParser struct{}
+
+var _ parser.BlockParser = (*BlockParser)(nil)
+
+// Trigger returns characters that trigger this parser.
+func (p *BlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Open opens a display math block on a line starting with $$.
+func (p *BlockParser) Open(_ ast.Node, reader text.Reader, _ parser.Context) (ast.Node, parser.State) {
+	line, seg := reader.PeekLine()
+
+	pos := util.TrimLeftSpaceLength(line)
+	if pos > 3 || !bytes.HasPrefix(line[pos:], _delim) {
+		return nil, parser.NoChildren
+	}
+
+	node := &Block{}
+	rest := util.TrimRightSpace(line[pos+2:])
+
+	// $$x^2$$ on a single line
+	if stop := bytes.Index(rest, _delim); stop >= 0 {
+		if len(bytes.TrimSpace(rest[stop+2:])) > 0 {
+			return nil, parser.NoChildren // more text follows, leave it to the inline parser
+		}
+
+		node.Lines().Append(text.NewSegment(seg.Start+pos+2, seg.Start+pos+2+stop))
+		node.closed = true
+		reader.AdvanceToEOL()
+
+		return node, parser.NoChildren
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		node.Lines().Append(text.NewSegment(seg.Start+pos+2, seg.Stop))
+	}
+
+	reader.AdvanceToEOL()
+
+	return node, parser.NoChildren
+}
+
+// Continue adds lines to the block until a line ending with $$.
+func (p *BlockParser) Continue(node ast.Node, reader text.Reader, _ parser.Context) parser.State {
+	if node.(*Block).closed {
+		return parser.Close
+	}
+
+	line, seg := reader.PeekLine()
+
+	trimmed := util.TrimRightSpace(line)
+	if bytes.HasSuffix(trimmed, _delim) {
+		if before := len(trimmed) - 2; len(bytes.TrimSpace(trimmed[:before])) > 0 {
+			node.Lines().Append(text.NewSegment(seg.Start, seg.Start+before))
+		}
+
+		reader.AdvanceToEOL()
+
+		return parser.Close
+	}
+
+	node.Lines().Append(seg)
+	reader.AdvanceToEOL()
+
+	return parser.Continue | parser.NoChildren
+}
+
+// Close does nothing.
+func (p *BlockParser) Close(_ ast.Node, _ text.Reader, _ parser.Context) {}
+
+// CanInterruptParagraph reports that display math can interrupt paragraphs.
+func (p *BlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+// CanAcceptIndentedLine reports that display math can't start with an
+// indented code block indentation.
+func (p *BlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
@@ -0,0 +1,81 @@
+package mathml
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Unsupported is a TeX command that couldn't be rendered, or a problem of
+// malformed TeX (e.g. "unbalanced {"), on Line of the source.
+type Unsupported struct {
+	Command string
+	Line    int
+}
+
+// Renderer renders math as MathML.
+//
+// * Unsupported: commands that couldn't be rendered, populated on render
+type Renderer struct {
+	Unsupported []Unsupported
+}
+
+var _ renderer.NodeRenderer = (*Renderer)(nil)
+
+// RegisterFuncs registers math rendering functions with the provided goldmark
+// registerer.
+func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindInline, r.renderInline)
+	reg.Register(KindBlock, r.renderBlock)
+}
+
+func (r *Renderer) renderInline(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*Inline)
+
+	r.render(w, src, string(n.TeX), n.Display, n.start)
+
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *Renderer) renderBlock(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	lines := node.Lines()
+
+	var tex bytes.Buffer
+
+	for i := range lines.Len() {
+		seg := lines.At(i)
+		tex.Write(seg.Value(src))
+	}
+
+	start := 0
+	if lines.Len() > 0 {
+		start = lines.At(0).Start
+	}
+
+	r.render(w, src, tex.String(), true, start)
+	_ = w.WriteByte('\n')
+
+	return ast.WalkSkipChildren, nil
+}
+
+// render converts tex and records its unsupported commands
+func (r *Renderer) render(w util.BufWriter, src []byte, tex string, display bool, start int) {
+	out, unsupported := Convert(tex, display)
+
+	line := bytes.Count(src[:min(start, len(src))], []byte{'\n'}) + 1
+	for _, cmd := range unsupported {
+		r.Unsupported = append(r.Unsupported, Unsupported{Command: cmd, Line: line})
+	}
+
+	_, _ = w.WriteString(out)
+}
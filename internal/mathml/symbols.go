@@ -0,0 +1,193 @@
+package mathml
+
+// Identifiers, rendered in <mi>. Capital greek letters are upright.
+var identifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"omicron": "ο", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "hbar": "ℏ", "ell": "ℓ",
+	"emptyset": "∅", "varnothing": "∅", "aleph": "ℵ", "beth": "ℶ", "Re": "ℜ",
+	"Im": "ℑ", "wp": "℘", "imath": "ı", "jmath": "ȷ", "top": "⊤", "bot": "⊥",
+}
+
+var uprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+}
+
+// Operators, relations, arrows and delimiters, rendered in <mo>
+var operators = map[string]string{
+	// binary operators
+	"cdot": "⋅", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "oslash": "⊘", "odot": "⊙", "cup": "∪", "cap": "∩",
+	"sqcup": "⊔", "sqcap": "⊓", "vee": "∨", "lor": "∨", "wedge": "∧",
+	"land": "∧", "setminus": "∖", "wr": "≀", "dagger": "†", "ddagger": "‡",
+	"amalg": "⨿",
+	// relations
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+	"preceq": "⪯", "succeq": "⪰", "in": "∈", "notin": "∉", "ni": "∋",
+	"subset": "⊂", "supset": "⊃", "subseteq": "⊆", "supseteq": "⊇",
+	"subsetneq": "⊊", "supsetneq": "⊋", "perp": "⊥", "parallel": "∥",
+	"mid": "∣", "nmid": "∤", "vdash": "⊢", "dashv": "⊣", "models": "⊨",
+	"asymp": "≍", "doteq": "≐", "leqslant": "⩽", "geqslant": "⩾",
+	"lesssim": "≲", "gtrsim": "≳", "coloneqq": "≔", "triangleq": "≜",
+	// arrows
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺",
+	"longrightarrow": "⟶", "longleftarrow": "⟵", "Longrightarrow": "⟹",
+	"Longleftarrow": "⟸", "longleftrightarrow": "⟷",
+	"Longleftrightarrow": "⟺", "mapsto": "↦", "longmapsto": "⟼",
+	"uparrow": "↑", "downarrow": "↓", "updownarrow": "↕", "Uparrow": "⇑",
+	"Downarrow": "⇓", "nearrow": "↗", "searrow": "↘", "swarrow": "↙",
+	"nwarrow": "↖", "hookrightarrow": "↪", "hookleftarrow": "↩",
+	"rightharpoonup": "⇀", "leftharpoonup": "↼", "rightleftharpoons": "⇌",
+	// logic and misc
+	"forall": "∀", "exists": "∃", "nexists": "∄", "neg": "¬", "lnot": "¬",
+	"therefore": "∴", "because": "∵", "angle": "∠", "triangle": "△",
+	"square": "□", "Box": "□", "diamond": "⋄", "prime": "′", "colon": ":",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"dotsc": "…", "dotsb": "⋯",
+	// delimiters
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "vert": "|", "lvert": "|", "rvert": "|",
+	"Vert": "‖", "lVert": "‖", "rVert": "‖", "|": "‖", "{": "{", "}": "}",
+	"lbrace": "{", "rbrace": "}", "lbrack": "[", "rbrack": "]",
+	"backslash": "∖",
+	// escaped characters
+	"#": "#", "$": "$", "%": "%", "&": "&", "_": "_",
+}
+
+// Operators taking limits below and above in display mode
+var largeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigodot": "⨀", "bigvee": "⋁",
+	"bigwedge": "⋀", "bigsqcup": "⨆", "biguplus": "⨄",
+}
+
+// Integrals, taking limits as scripts
+var integrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// Named functions, upright
+var functions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true,
+	"csc": true, "arcsin": true, "arccos": true, "arctan": true, "sinh": true,
+	"cosh": true, "tanh": true, "coth": true, "log": true, "ln": true,
+	"lg": true, "exp": true, "dim": true, "ker": true, "deg": true,
+	"arg": true, "hom": true,
+}
+
+// Named functions taking limits below in display mode
+var limitFunctions = map[string]string{
+	"lim": "lim", "liminf": "lim inf", "limsup": "lim sup", "max": "max",
+	"min": "min", "sup": "sup", "inf": "inf", "det": "det", "gcd": "gcd",
+	"Pr": "Pr", "argmax": "arg max", "argmin": "arg min",
+}
+
+// Horizontal spaces
+var spaces = map[string]string{
+	",": "0.1667em", "thinspace": "0.1667em", ":": "0.2222em",
+	">": "0.2222em", "medspace": "0.2222em", ";": "0.2778em",
+	"thickspace": "0.2778em", "!": "-0.1667em", "negthinspace": "-0.1667em",
+	" ": "0.25em", "enspace": "0.5em", "quad": "1em", "qquad": "2em",
+}
+
+type accent struct {
+	char     string
+	stretchy bool
+	under    bool
+}
+
+// Accents over or under their argument
+var accents = map[string]accent{
+	"hat": {char: "^"}, "widehat": {char: "^", stretchy: true},
+	"check": {char: "ˇ"}, "tilde": {char: "~"},
+	"widetilde": {char: "~", stretchy: true}, "acute": {char: "´"},
+	"grave": {char: "`"}, "dot": {char: "˙"}, "ddot": {char: "¨"},
+	"breve": {char: "˘"}, "bar": {char: "¯"}, "vec": {char: "→"},
+	"overline":       {char: "‾", stretchy: true},
+	"overrightarrow": {char: "→", stretchy: true},
+	"overleftarrow":  {char: "←", stretchy: true},
+	"overbrace":      {char: "⏞", stretchy: true},
+	"underline":      {char: "_", stretchy: true, under: true},
+	"underbrace":     {char: "⏟", stretchy: true, under: true},
+}
+
+// Font commands, with the math variant of their argument
+var fonts = map[string]string{
+	"mathbf": "bold", "mathit": "italic", "mathbb": "double-struck",
+	"mathcal": "script", "mathscr": "script", "mathfrak": "fraktur",
+	"mathsf": "sans-serif", "mathtt": "monospace", "mathrm": "normal",
+	"boldsymbol": "bold-italic", "bm": "bold-italic",
+}
+
+// Text commands, whose argument is rendered as is
+var texts = map[string]bool{
+	"text": true, "textrm": true, "textit": true, "textbf": true,
+	"textsf": true, "texttt": true, "mbox": true, "textnormal": true,
+}
+
+// Delimiter sizes of \big and co.
+var bigSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "bigm": "1.2em",
+	"Big": "1.8em", "Bigl": "1.8em", "Bigr": "1.8em", "Bigm": "1.8em",
+	"bigg": "2.4em", "biggl": "2.4em", "biggr": "2.4em", "biggm": "2.4em",
+	"Bigg": "3em", "Biggl": "3em", "Biggr": "3em", "Biggm": "3em",
+}
+
+// Commands without output
+var ignored = map[string]bool{
+	"displaystyle": true, "textstyle": true, "scriptstyle": true,
+	"scriptscriptstyle": true, "limits": true, "nolimits": true,
+	"nonumber": true, "notag": true, "relax": true,
+}
+
+// Delimiters of matrix environments
+var matrices = map[string][2]string{
+	"matrix": {"", ""}, "smallmatrix": {"", ""}, "pmatrix": {"(", ")"},
+	"bmatrix": {"[", "]"}, "Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"},
+	"Vmatrix": {"‖", "‖"},
+}
+
+// Environments of aligned equations
+var alignments = map[string]bool{
+	"aligned": true, "align": true, "align*": true, "split": true,
+	"gathered": true, "gather": true, "gather*": true, "equation": true,
+	"equation*": true, "alignat": true, "alignat*": true,
+}
+
+// Exceptions to the contiguous ranges of the Mathematical Alphanumeric
+// Symbols block, which are in the Letterlike Symbols block instead
+var letterlike = map[string]map[rune]rune{
+	"italic": {'h': 'ℎ'},
+	"script": {
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	},
+	"fraktur": {'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ'},
+	"double-struck": {
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	},
+}
+
+// Starts of capital letters, small letters and digits of each math variant
+// in the Mathematical Alphanumeric Symbols block, 0 if there are none
+var alphanumerics = map[string][3]rune{
+	"bold":          {0x1D400, 0x1D41A, 0x1D7CE},
+	"italic":        {0x1D434, 0x1D44E, 0},
+	"bold-italic":   {0x1D468, 0x1D482, 0},
+	"script":        {0x1D49C, 0x1D4B6, 0},
+	"fraktur":       {0x1D504, 0x1D51E, 0},
+	"double-struck": {0x1D538, 0x1D552, 0x1D7D8},
+	"sans-serif":    {0x1D5A0, 0x1D5BA, 0x1D7E2},
+	"monospace":     {0x1D670, 0x1D68A, 0x1D7F6},
+}
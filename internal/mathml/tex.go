@@ -0,0 +1,701 @@
+package mathml
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// apply is the invisible function application operator, following functions
+const apply = "<mo>\u2061</mo>"
+
+// kinds of atoms, deciding where their scripts go
+const (
+	atomOrdinary = iota
+	atomLargeOperator
+	atomIntegral
+)
+
+// converter converts TeX to MathML in a single pass.
+//
+// * unsupported: commands and environments that couldn't be converted, and
+// problems of malformed input
+type converter struct {
+	src         string
+	pos         int
+	display     bool
+	variant     string
+	unsupported []string
+}
+
+// Convert converts TeX math to a MathML <math> element, in display mode if
+// display is set. It returns the commands it doesn't support, which are
+// rendered as errors, and the problems of malformed input (e.g. "missing
+// argument of \frac" or "unbalanced {"), which are rendered as best it can.
+func Convert(tex string, display bool) (string, []string) {
+	c := &converter{src: tex, display: display}
+
+	body := c.parseTable("", nil)
+
+	var b strings.Builder
+
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+
+	if display {
+		b.WriteString(` display="block"`)
+	}
+
+	b.WriteString("><semantics><mrow>")
+	b.WriteString(body)
+	b.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+
+	return b.String(), c.unsupported
+}
+
+// next returns the next token: a command with its backslash (e.g. `\frac`,
+// `\,`), or a single character. Whitespace and comments are skipped. It
+// returns "" at the end of the source.
+func (c *converter) next() string {
+	c.skipSpace()
+
+	if c.pos >= len(c.src) {
+		return ""
+	}
+
+	if c.src[c.pos] != '\\' {
+		_, size := utf8.DecodeRuneInString(c.src[c.pos:])
+		tok := c.src[c.pos : c.pos+size]
+		c.pos += size
+
+		return tok
+	}
+
+	start := c.pos
+	c.pos++
+
+	for c.pos < len(c.src) && isLetter(c.src[c.pos]) {
+		c.pos++
+	}
+
+	// control symbols, e.g. \, or \{
+	if c.pos == start+1 && c.pos < len(c.src) {
+		_, size := utf8.DecodeRuneInString(c.src[c.pos:])
+		c.pos += size
+	}
+
+	// starred variants, e.g. \operatorname*
+	if c.pos < len(c.src) && c.src[c.pos] == '*' && c.pos > start+1 && isLetter(c.src[c.pos-1]) {
+		c.pos++
+	}
+
+	return c.src[start:c.pos]
+}
+
+// peek returns the next token without consuming it
+func (c *converter) peek() string {
+	pos := c.pos
+	tok := c.next()
+	c.pos = pos
+
+	return tok
+}
+
+func (c *converter) skipSpace() {
+	for c.pos < len(c.src) {
+		switch ch := c.src[c.pos]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			c.pos++
+		case ch == '%':
+			for c.pos < len(c.src) && c.src[c.pos] != '\n' {
+				c.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// raw returns the contents of the next {...} group as written, or of the
+// next token if there is no group
+func (c *converter) raw() string {
+	c.skipSpace()
+
+	if c.pos >= len(c.src) || c.src[c.pos] != '{' {
+		return c.next()
+	}
+
+	depth := 0
+	start := c.pos + 1
+
+	for ; c.pos < len(c.src); c.pos++ {
+		switch c.src[c.pos] {
+		case '\\':
+			c.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				c.pos++
+				return c.src[start : c.pos-1]
+			}
+		}
+	}
+
+	c.unsupport("unbalanced {")
+
+	return c.src[start:]
+}
+
+// optional returns the contents of the next [...] argument, converted, and
+// whether there was one
+func (c *converter) optional() (string, bool) {
+	if c.peek() != "[" {
+		return "", false
+	}
+
+	c.next()
+
+	arg := c.parseRow("]")
+	if c.next() != "]" {
+		c.unsupport("unbalanced [")
+	}
+
+	return arg, true
+}
+
+// parseTable parses rows separated by \\ and cells separated by &, until the
+// \end of env (or the end of the source if env is empty). A single cell is
+// returned as is, else as an <mtable>.
+func (c *converter) parseTable(env string, columnAlign []string) string {
+	rows := [][]string{{}}
+
+	for {
+		cell := c.parseRow("&", `\\`, `\cr`, `\end`)
+		rows[len(rows)-1] = append(rows[len(rows)-1], cell)
+
+		tok := c.next()
+		if tok == "" {
+			break
+		}
+
+		if tok == `\end` {
+			if name := c.raw(); name != env {
+				c.unsupport(`\end{` + name + `}`)
+			}
+
+			break
+		}
+
+		if tok == `\\` || tok == `\cr` {
+			// spacing after \\, e.g. \\[2pt]
+			if c.peek() == "[" {
+				c.raw()
+				c.parseRow("]")
+				c.next()
+			}
+
+			rows = append(rows, []string{})
+		}
+	}
+
+	// drop an empty last row, left by a trailing \\
+	if last := rows[len(rows)-1]; len(rows) > 1 && len(last) == 1 && len(last[0]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+
+	if len(env) == 0 && len(rows) == 1 && len(rows[0]) == 1 {
+		return rows[0][0]
+	}
+
+	var b strings.Builder
+
+	b.WriteString("<mtable")
+
+	if len(columnAlign) > 0 {
+		b.WriteString(` columnalign="` + strings.Join(columnAlign, " ") + `"`)
+	}
+
+	if c.display || alignments[env] {
+		b.WriteString(` displaystyle="true"`)
+	}
+
+	b.WriteString(">")
+
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+
+		for _, cell := range row {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+
+		b.WriteString("</mtr>")
+	}
+
+	b.WriteString("</mtable>")
+
+	return b.String()
+}
+
+// parseRow parses atoms until one of the stop tokens (left unconsumed) or
+// the end of the source
+func (c *converter) parseRow(stops ...string) string {
+	var b strings.Builder
+
+	for {
+		tok := c.peek()
+		if tok == "" || slices.Contains(stops, tok) {
+			return b.String()
+		}
+
+		// unbalanced tokens
+		switch tok {
+		case "}":
+			c.next()
+			c.unsupport("unbalanced }")
+
+			continue
+		case "&", `\\`, `\cr`:
+			c.next()
+
+			continue
+		case `\right`:
+			c.next()
+			c.unsupport(`\right without \left`)
+			c.delimiter()
+
+			continue
+		case `\end`:
+			c.next()
+			c.unsupport(`\end{` + c.raw() + `}`)
+
+			continue
+		}
+
+		b.WriteString(c.parseScripts())
+	}
+}
+
+// parseScripts parses an atom and its subscript, superscript and primes
+func (c *converter) parseScripts() string {
+	base, kind := "<mrow></mrow>", atomOrdinary
+	if tok := c.peek(); tok != "^" && tok != "_" {
+		base, kind = c.parseAtom()
+	}
+
+	var sub, sup string
+
+	for {
+		switch c.peek() {
+		case "^":
+			c.next()
+
+			arg, _ := c.argument("^")
+			sup += arg
+		case "_":
+			c.next()
+
+			arg, _ := c.argument("_")
+			sub += arg
+		case "'":
+			c.next()
+
+			sup += "<mo>′</mo>"
+		default:
+			// scripts of functions go before the function application
+			if strings.HasSuffix(base, apply) {
+				return scripts(strings.TrimSuffix(base, apply), sub, sup, false) + apply
+			}
+
+			return scripts(base, sub, sup, kind == atomLargeOperator && c.display)
+		}
+	}
+}
+
+// scripts attaches sub and sup to base, below and above it if limits is set
+func scripts(base, sub, sup string, limits bool) string {
+	if len(sub) == 0 && len(sup) == 0 {
+		return base
+	}
+
+	base = mrow(base)
+
+	under, over, both := "msub", "msup", "msubsup"
+	if limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+
+	switch {
+	case len(sub) > 0 && len(sup) > 0:
+		return "<" + both + ">" + base + mrow(sub) + mrow(sup) + "</" + both + ">"
+	case len(sub) > 0:
+		return "<" + under + ">" + base + mrow(sub) + "</" + under + ">"
+	}
+
+	return "<" + over + ">" + base + mrow(sup) + "</" + over + ">"
+}
+
+// parseAtom parses a single atom: a group, a character or a command with its
+// arguments
+func (c *converter) parseAtom() (string, int) {
+	tok := c.next()
+
+	switch {
+	case tok == "":
+		return "<mrow></mrow>", atomOrdinary
+	case tok == "{":
+		row := c.parseRow("}")
+		if c.next() != "}" {
+			c.unsupport("unbalanced {")
+		}
+
+		return mrow(row), atomOrdinary
+	case strings.HasPrefix(tok, `\`) && len(tok) > 1:
+		return c.parseCommand(tok[1:])
+	case isDigit(tok[0]) || (tok == "." && c.pos < len(c.src) && isDigit(c.src[c.pos])):
+		num := tok
+		for c.pos < len(c.src) && (isDigit(c.src[c.pos]) || c.src[c.pos] == '.') {
+			num += c.src[c.pos : c.pos+1]
+			c.pos++
+		}
+
+		return "<mn>" + c.styled(num) + "</mn>", atomOrdinary
+	case isLetterRune(tok):
+		if c.variant == "normal" {
+			return `<mi mathvariant="normal">` + tok + "</mi>", atomOrdinary
+		}
+
+		return "<mi>" + c.styled(tok) + "</mi>", atomOrdinary
+	case tok == "~":
+		return `<mspace width="0.25em"></mspace>`, atomOrdinary
+	}
+
+	return mo(operator(tok)), atomOrdinary
+}
+
+// argument parses the atom that is an argument of the command or script of,
+// reporting it missing at the end of the source or of its group, row or cell
+func (c *converter) argument(of string) (string, int) {
+	switch c.peek() {
+	case "", "}", "&", `\\`, `\cr`, `\end`, `\right`:
+		c.unsupport("missing argument of " + of)
+
+		return "<mrow></mrow>", atomOrdinary
+	}
+
+	return c.parseAtom()
+}
+
+// parseCommand parses the command name (without backslash) and its arguments
+func (c *converter) parseCommand(name string) (string, int) {
+	if s, ok := identifiers[name]; ok {
+		return "<mi>" + s + "</mi>", atomOrdinary
+	}
+
+	if s, ok := uprightIdentifiers[name]; ok {
+		return `<mi mathvariant="normal">` + s + "</mi>", atomOrdinary
+	}
+
+	if s, ok := operators[name]; ok {
+		return mo(html.EscapeString(s)), atomOrdinary
+	}
+
+	if s, ok := largeOperators[name]; ok {
+		return `<mo largeop="true" movablelimits="true">` + s + "</mo>", atomLargeOperator
+	}
+
+	if s, ok := integrals[name]; ok {
+		return `<mo largeop="true">` + s + "</mo>", atomIntegral
+	}
+
+	if functions[name] {
+		return "<mi>" + name + "</mi>" + apply, atomOrdinary
+	}
+
+	if s, ok := limitFunctions[name]; ok {
+		return `<mo movablelimits="true" form="prefix">` + s + "</mo>", atomLargeOperator
+	}
+
+	if w, ok := spaces[name]; ok {
+		return `<mspace width="` + w + `"></mspace>`, atomOrdinary
+	}
+
+	if a, ok := accents[name]; ok {
+		arg, _ := c.argument(`\` + name)
+		op := `<mo stretchy="` + boolString(a.stretchy) + `">` + a.char + "</mo>"
+
+		if a.under {
+			return `<munder accentunder="true">` + arg + op + "</munder>", atomOrdinary
+		}
+
+		return `<mover accent="true">` + arg + op + "</mover>", atomOrdinary
+	}
+
+	if v, ok := fonts[name]; ok {
+		prev := c.variant
+		c.variant = v
+		arg, _ := c.argument(`\` + name)
+		c.variant = prev
+
+		return arg, atomOrdinary
+	}
+
+	if texts[name] {
+		return "<mtext>" + html.EscapeString(c.raw()) + "</mtext>", atomOrdinary
+	}
+
+	if size, ok := bigSizes[name]; ok {
+		delim := c.delimiter()
+
+		return `<mo stretchy="true" minsize="` + size + `" maxsize="` + size + `">` + delim + "</mo>", atomOrdinary
+	}
+
+	if ignored[name] {
+		return "", atomOrdinary
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, _ := c.argument(`\` + name)
+		den, _ := c.argument(`\` + name)
+
+		return "<mfrac>" + num + den + "</mfrac>", atomOrdinary
+	case "binom", "dbinom", "tbinom":
+		top, _ := c.argument(`\` + name)
+		bottom, _ := c.argument(`\` + name)
+
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + "</mfrac><mo>)</mo></mrow>", atomOrdinary
+	case "sqrt":
+		index, ok := c.optional()
+		arg, _ := c.argument(`\sqrt`)
+
+		if ok {
+			return "<mroot>" + arg + mrow(index) + "</mroot>", atomOrdinary
+		}
+
+		return "<msqrt>" + arg + "</msqrt>", atomOrdinary
+	case "left":
+		open := c.delimiter()
+		body := c.parseRow(`\right`)
+
+		close := ""
+		if c.next() == `\right` {
+			close = c.delimiter()
+		} else {
+			c.unsupport(`\left without \right`)
+		}
+
+		return "<mrow>" + fence(open) + body + fence(close) + "</mrow>", atomOrdinary
+	case "middle":
+		return fence(c.delimiter()), atomOrdinary
+	case "not":
+		arg, kind := c.argument(`\not`)
+		if strings.HasSuffix(arg, "</mo>") {
+			return strings.TrimSuffix(arg, "</mo>") + "̸</mo>", kind
+		}
+
+		return arg, kind
+	case "overset", "stackrel":
+		over, _ := c.argument(`\` + name)
+		base, _ := c.argument(`\` + name)
+
+		return "<mover>" + base + over + "</mover>", atomOrdinary
+	case "underset":
+		under, _ := c.argument(`\underset`)
+		base, _ := c.argument(`\underset`)
+
+		return "<munder>" + base + under + "</munder>", atomOrdinary
+	case "operatorname", "operatorname*":
+		op := html.EscapeString(strings.TrimSpace(c.raw()))
+		if name == "operatorname*" {
+			return `<mo movablelimits="true" form="prefix">` + op + "</mo>", atomLargeOperator
+		}
+
+		return "<mi>" + op + "</mi>" + apply, atomOrdinary
+	case "mathop":
+		arg, _ := c.argument(`\mathop`)
+
+		return arg, atomLargeOperator
+	case "begin":
+		return c.parseEnvironment(c.raw()), atomOrdinary
+	case "mod", "bmod":
+		return `<mo lspace="0.2778em" rspace="0.2778em">mod</mo>`, atomOrdinary
+	case "pmod":
+		arg, _ := c.argument(`\pmod`)
+
+		return `<mspace width="0.4444em"></mspace><mo>(</mo><mi>mod</mi><mspace width="0.3333em"></mspace>` + arg + "<mo>)</mo>", atomOrdinary
+	}
+
+	c.unsupport(`\` + name)
+
+	return `<merror><mtext>\` + html.EscapeString(name) + "</mtext></merror>", atomOrdinary
+}
+
+// parseEnvironment parses the body of \begin{env}
+func (c *converter) parseEnvironment(env string) string {
+	if delims, ok := matrices[env]; ok {
+		table := c.parseTable(env, nil)
+		if len(delims[0]) == 0 {
+			return table
+		}
+
+		return "<mrow>" + fence(delims[0]) + table + fence(delims[1]) + "</mrow>"
+	}
+
+	switch {
+	case env == "cases":
+		return "<mrow>" + fence("{") + c.parseTable(env, []string{"left", "left"}) + "</mrow>"
+	case env == "array":
+		spec := c.raw()
+		align := []string{}
+
+		for _, ch := range spec {
+			switch ch {
+			case 'l':
+				align = append(align, "left")
+			case 'c':
+				align = append(align, "center")
+			case 'r':
+				align = append(align, "right")
+			}
+		}
+
+		return c.parseTable(env, align)
+	case alignments[env]:
+		// alignat takes the number of columns
+		if strings.HasPrefix(env, "alignat") {
+			c.raw()
+		}
+
+		if strings.HasPrefix(env, "gather") || strings.HasPrefix(env, "equation") {
+			return c.parseTable(env, nil)
+		}
+
+		return c.parseTable(env, []string{"right", "left"})
+	}
+
+	c.unsupport(`\begin{` + env + `}`)
+
+	return c.parseTable(env, nil)
+}
+
+// delimiter returns the delimiter following \left, \right or \big
+func (c *converter) delimiter() string {
+	tok := c.next()
+
+	switch {
+	case tok == "":
+		c.unsupport("missing delimiter")
+
+		return ""
+	case tok == ".":
+		return ""
+	case strings.HasPrefix(tok, `\`):
+		if s, ok := operators[tok[1:]]; ok {
+			return html.EscapeString(s)
+		}
+
+		c.unsupport(tok)
+
+		return ""
+	}
+
+	return html.EscapeString(tok)
+}
+
+// styled maps the letters and digits of s to the current math variant
+func (c *converter) styled(s string) string {
+	starts, ok := alphanumerics[c.variant]
+	if !ok {
+		return html.EscapeString(s)
+	}
+
+	var b strings.Builder
+
+	for _, r := range s {
+		if l, ok := letterlike[c.variant][r]; ok {
+			b.WriteRune(l)
+
+			continue
+		}
+
+		switch {
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(starts[0] + r - 'A')
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(starts[1] + r - 'a')
+		case r >= '0' && r <= '9' && starts[2] != 0:
+			b.WriteRune(starts[2] + r - '0')
+		default:
+			b.WriteString(html.EscapeString(string(r)))
+		}
+	}
+
+	return b.String()
+}
+
+// unsupport records cmd, an unsupported command or a problem of the input
+func (c *converter) unsupport(cmd string) {
+	if !slices.Contains(c.unsupported, cmd) {
+		c.unsupported = append(c.unsupported, cmd)
+	}
+}
+
+// operator returns the operator of the character tok
+func operator(tok string) string {
+	switch tok {
+	case "-":
+		return "−"
+	case "*":
+		return "∗"
+	}
+
+	return html.EscapeString(tok)
+}
+
+func mo(s string) string {
+	switch s {
+	case "(", ")", "[", "]", "|", "{", "}", "‖", "⟨", "⟩", "⌊", "⌋", "⌈", "⌉":
+		return `<mo stretchy="false">` + s + "</mo>"
+	}
+
+	return "<mo>" + s + "</mo>"
+}
+
+func fence(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	return `<mo fence="true" stretchy="true">` + s + "</mo>"
+}
+
+func mrow(s string) string {
+	return "<mrow>" + s + "</mrow>"
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+
+	return "false"
+}
+
+func isLetter(b byte) bool {
+	return b < utf8.RuneSelf && unicode.IsLetter(rune(b))
+}
+
+func isLetterRune(tok string) bool {
+	r, _ := utf8.DecodeRuneInString(tok)
+
+	return unicode.IsLetter(r)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
@@ -0,0 +1,92 @@
+package mathml
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string
+	}{
+		{`x`, `<mi>x</mi>`},
+		{`3.14`, `<mn>3.14</mn>`},
+		{`a - b`, `<mi>a</mi><mo>−</mo><mi>b</mi>`},
+		{`x^2`, `<msup><mrow><mi>x</mi></mrow><mrow><mn>2</mn></mrow></msup>`},
+		{`x_i^2`, `<msubsup><mrow><mi>x</mi></mrow><mrow><mi>i</mi></mrow><mrow><mn>2</mn></mrow></msubsup>`},
+		{`f'`, `<msup><mrow><mi>f</mi></mrow><mrow><mo>′</mo></mrow></msup>`},
+		{`\frac{a}{b}`, `<mfrac><mrow><mi>a</mi></mrow><mrow><mi>b</mi></mrow></mfrac>`},
+		{`\sqrt[3]{x}`, `<mroot><mrow><mi>x</mi></mrow><mrow><mn>3</mn></mrow></mroot>`},
+		{`\alpha`, `<mi>α</mi>`},
+		{`\sin x`, "<mi>sin</mi><mo>⁡</mo><mi>x</mi>"},
+		{`\mathbb{R}`, `<mi>ℝ</mi>`},
+		{`\text{a < b}`, `<mtext>a &lt; b</mtext>`},
+		{`\left( x \right.`, `<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi></mrow>`},
+		{
+			`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`,
+			`<mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable>`,
+		},
+		{`\foo`, `<merror><mtext>\foo</mtext></merror>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tex, func(t *testing.T) {
+			out, _ := Convert(tt.tex, false)
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("got %s, want it to contain %s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertDisplay(t *testing.T) {
+	tests := []struct {
+		display bool
+		want    []string
+	}{
+		{false, []string{`<math xmlns="http://www.w3.org/1998/Math/MathML">`, `<msub><mrow><mo largeop="true"`}},
+		{true, []string{`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`, `<munder><mrow><mo largeop="true"`}},
+	}
+
+	for _, tt := range tests {
+		out, _ := Convert(`\sum_i`, tt.display)
+
+		for _, w := range tt.want {
+			if !strings.Contains(out, w) {
+				t.Errorf("got %s, want it to contain %s", out, w)
+			}
+		}
+	}
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want []string
+	}{
+		{`\frac{1}{2} + x_i^2`, nil},
+		{`\foo + \bar{x}`, []string{`\foo`}},
+		{`\begin{tikzpicture} x \end{tikzpicture}`, []string{`\begin{tikzpicture}`}},
+		{`\frac{a}`, []string{`missing argument of \frac`}},
+		{`x_`, []string{`missing argument of _`}},
+		{`{x^}`, []string{`missing argument of ^`}},
+		{`{a`, []string{`unbalanced {`}},
+		{`a}`, []string{`unbalanced }`}},
+		{`\text{a`, []string{`unbalanced {`}},
+		{`\sqrt[3`, []string{`unbalanced [`, `missing argument of \sqrt`}},
+		{`\left( x`, []string{`\left without \right`}},
+		{`x \right)`, []string{`\right without \left`}},
+		{`\big`, []string{`missing delimiter`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tex, func(t *testing.T) {
+			_, unsupported := Convert(tt.tex, false)
+			if !slices.Equal(unsupported, tt.want) {
+				t.Errorf("got %q, want %q", unsupported, tt.want)
+			}
+		})
+	}
+}
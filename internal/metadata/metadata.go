@@ -16,18 +16,54 @@ package metadata
 // * Draft: false
 //
 // * TOC: false
+//
+// * NoIndex: false
+//
+// * Feed: false
+//
+// * Paginate: 0 (no pagination)
+//
+// * Weight: 0
+//
+// * Sort: "" (the sort of the config)
+//
+// Pages are only published from PublishDate (or else Date) and until
+// ExpiryDate, if set. Unpublished isn't frontmatter: it is set on pages
+// outside of these dates as of the build.
+//
+// TOCTitle, TOCPlacement, TOCMinLevel and TOCMaxLevel override the toc
+// options of the config if set.
 type Metadata struct {
-	Title       string   `yaml:"title"`
-	Description string   `yaml:"description"`
-	Date        string   `yaml:"date"`
-	DateUpdated string   `yaml:"dateUpdated"`
-	Layout      string   `yaml:"layout"`
-	Tags        []string `yaml:"tags"`
-	Pinned      bool     `yaml:"pinned"`
-	Unlisted    bool     `yaml:"unlisted"`
-	Draft       bool     `yaml:"draft"`
-	TOC         bool     `yaml:"toc"`
-	ShowHeader  bool     `yaml:"showHeader"`
+	Title        string   `yaml:"title"`
+	Description  string   `yaml:"description"`
+	Date         string   `yaml:"date"`
+	DateUpdated  string   `yaml:"dateUpdated"`
+	PublishDate  string   `yaml:"publishDate"`
+	ExpiryDate   string   `yaml:"expiryDate"`
+	Layout       string   `yaml:"layout"`
+	Sort         string   `yaml:"sort"`
+	TOCTitle     string   `yaml:"tocTitle"`
+	TOCPlacement string   `yaml:"tocPlacement"`
+	Tags         []string `yaml:"tags"`
+	Aliases      []string `yaml:"aliases"`
+	Paginate     int      `yaml:"paginate"`
+	Weight       int      `yaml:"weight"`
+	TOCMinLevel  int      `yaml:"tocMinLevel"`
+	TOCMaxLevel  int      `yaml:"tocMaxLevel"`
+	Pinned       bool     `yaml:"pinned"`
+	Unlisted     bool     `yaml:"unlisted"`
+	Draft        bool     `yaml:"draft"`
+	TOC          bool     `yaml:"toc"`
+	ShowHeader   bool     `yaml:"showHeader"`
+	NoIndex      bool     `yaml:"noindex"`
+	Feed         bool     `yaml:"feed"`
+	Unpublished  bool     `yaml:"-"`
+}
+
+// Hidden reports whether the page is left out of the site: it is a draft,
+// or unpublished
+func (m Metadata) Hidden() bool {
+	return m.Draft || m.Unpublished
 }
 
 // Default returns the defaults for unspecified frontmatter field values
@@ -39,5 +75,7 @@ func Default() *Metadata {
 		Layout:     "list",
 		Draft:      false,
 		TOC:        false,
+		NoIndex:    false,
+		Feed:       false,
 	}
 }
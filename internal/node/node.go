@@ -4,15 +4,23 @@ package node
 import (
 	"github.com/mstcl/pher/v3/internal/metadata"
 	"github.com/mstcl/pher/v3/internal/nodepathlink"
+	"github.com/mstcl/pher/v3/internal/source"
+	"github.com/mstcl/pher/v3/internal/toc"
 )
 
 // Node is an abstracted idea of a source markdown file. It is a file
 // represented in our state.
+//
+// * Links: links found in the source, as written
+//
+// * TOC: table of contents of the source
 type Node struct {
 	Href         string
 	Backlinks    []nodepathlink.NodePathLink
 	Relatedlinks []nodepathlink.NodePathLink
 	Body         []byte
 	ChromaCSS    []byte
+	TOC          *toc.TOC
+	Links        source.Links
 	Metadata     metadata.Metadata
 }
@@ -14,6 +14,8 @@ import "html/template"
 //
 // * IsDir: source is directory or not
 //
+// * Keys: what the link is sorted by, see Sort
+//
 // The rest are for Log View, similar to render.RenderData
 type NodePathLink struct {
 	Body               template.HTML
@@ -25,5 +27,6 @@ type NodePathLink struct {
 	MachineDate        string
 	MachineDateUpdated string
 	Tags               []string
+	Keys               Keys
 	IsDir              bool
 }
@@ -0,0 +1,85 @@
+package nodepathlink
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keys are what a NodePathLink is sorted by.
+//
+// * Date, Updated: zero if the source has no (updated) date
+//
+// * Filename: base of the source, or name of the nodegroup
+type Keys struct {
+	Date     time.Time
+	Updated  time.Time
+	Filename string
+	Weight   int
+	Pinned   bool
+}
+
+// Sorts is the list of orders links can be sorted in, each of which can be
+// reversed with a "-" prefix.
+var Sorts = []string{"date", "title", "weight", "updated", "filename"}
+
+// Sort sorts links in the order by, one of Sorts, optionally prefixed with
+// "-" to reverse it. Pinned links come first either way, and ties are broken
+// by filename. links are left untouched if by is unknown.
+func Sort(links []NodePathLink, by string) error {
+	key, reverse := strings.CutPrefix(by, "-")
+
+	var less func(a, b *NodePathLink) int
+
+	switch key {
+	case "date":
+		less = func(a, b *NodePathLink) int { return a.Keys.Date.Compare(b.Keys.Date) }
+	case "updated":
+		less = func(a, b *NodePathLink) int { return updated(a).Compare(updated(b)) }
+	case "title":
+		less = func(a, b *NodePathLink) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		}
+	case "weight":
+		less = func(a, b *NodePathLink) int { return a.Keys.Weight - b.Keys.Weight }
+	case "filename":
+		less = func(a, b *NodePathLink) int { return 0 }
+	default:
+		return fmt.Errorf("unknown sort %q, expected one of %v", by, Sorts)
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		a, b := &links[i], &links[j]
+
+		if a.Keys.Pinned != b.Keys.Pinned {
+			return a.Keys.Pinned
+		}
+
+		c := less(a, b)
+		if reverse {
+			c = -c
+		}
+
+		if c != 0 {
+			return c < 0
+		}
+
+		if reverse && key == "filename" {
+			return a.Keys.Filename > b.Keys.Filename
+		}
+
+		return a.Keys.Filename < b.Keys.Filename
+	})
+
+	return nil
+}
+
+// updated returns when l was last updated, its date if it never was
+func updated(l *NodePathLink) time.Time {
+	if l.Keys.Updated.IsZero() {
+		return l.Keys.Date
+	}
+
+	return l.Keys.Updated
+}
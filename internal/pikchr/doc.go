@@ -0,0 +1,9 @@
+// Package pikchr renders Pikchr diagrams (https://pikchr.org) to SVG in pure
+// Go, at build time.
+//
+// It implements the core of the language: the block objects (box, circle,
+// ellipse, oval, cylinder, file, diamond, dot and text), lines (line, arrow,
+// spline, move and arc) with their paths, text annotations and styles,
+// labels, places and positions, and variables with expressions. Sublists
+// ([...]), macros (define), print and assert are rejected with an error.
+package pikchr
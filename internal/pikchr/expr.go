@@ -0,0 +1,493 @@
+package pikchr
+
+import (
+	"math"
+	"strings"
+)
+
+// functions are the built-in functions of expressions, by arity
+var functions = map[string]func(args []float64) float64{
+	"abs":  func(a []float64) float64 { return math.Abs(a[0]) },
+	"cos":  func(a []float64) float64 { return math.Cos(a[0] * math.Pi / 180) },
+	"sin":  func(a []float64) float64 { return math.Sin(a[0] * math.Pi / 180) },
+	"sqrt": func(a []float64) float64 { return math.Sqrt(a[0]) },
+	"int":  func(a []float64) float64 { return math.Trunc(a[0]) },
+	"max":  func(a []float64) float64 { return math.Max(a[0], a[1]) },
+	"min":  func(a []float64) float64 { return math.Min(a[0], a[1]) },
+}
+
+var arity = map[string]int{
+	"abs": 1, "cos": 1, "sin": 1, "sqrt": 1, "int": 1, "max": 2, "min": 2,
+}
+
+// startsExpr reports whether the next token starts an expression, rather
+// than an attribute
+func (d *diagram) startsExpr() bool {
+	t := d.peek()
+
+	switch t.kind {
+	case tokNumber:
+		return !isOrdinal(t)
+	case tokPunct:
+		return t.text == "(" || t.text == "-" || t.text == "+"
+	case tokIdent:
+		if d.knownAttribute(t.text) {
+			return false
+		}
+
+		if _, ok := d.vars[t.text]; ok {
+			return true
+		}
+
+		if _, ok := functions[t.text]; ok {
+			return d.peekAt(1).text == "("
+		}
+
+		if _, ok := colors[strings.ToLower(t.text)]; ok {
+			return true
+		}
+
+		return isLabel(t.text) && d.peekAt(1).text == "."
+	}
+
+	return false
+}
+
+// try runs a parse, restoring the position of the parser if it fails
+func (d *diagram) try(parse func() error) bool {
+	pos := d.pos
+	if err := parse(); err != nil {
+		d.pos = pos
+
+		return false
+	}
+
+	return true
+}
+
+// expr reads an expression
+func (d *diagram) expr() (float64, error) {
+	v, err := d.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for d.is("+") || d.is("-") {
+		op := d.next().text
+
+		w, err := d.term()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == "+" {
+			v += w
+		} else {
+			v -= w
+		}
+	}
+
+	return v, nil
+}
+
+func (d *diagram) term() (float64, error) {
+	v, err := d.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	for d.is("*") || d.is("/") {
+		op := d.next().text
+
+		w, err := d.unary()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == "*" {
+			v *= w
+		} else {
+			if w == 0 {
+				return 0, d.errorf("division by zero")
+			}
+
+			v /= w
+		}
+	}
+
+	return v, nil
+}
+
+func (d *diagram) unary() (float64, error) {
+	switch {
+	case d.accept("-"):
+		v, err := d.unary()
+
+		return -v, err
+	case d.accept("+"):
+		return d.unary()
+	}
+
+	return d.primary()
+}
+
+func (d *diagram) primary() (float64, error) {
+	t := d.peek()
+
+	switch {
+	case t.kind == tokNumber && !isOrdinal(t):
+		d.next()
+
+		return t.num, nil
+	case d.accept("("):
+		v, err := d.expr()
+		if err != nil {
+			return 0, err
+		}
+
+		return v, d.expect(")")
+	case t.kind == tokIdent && d.peekAt(1).text == "(":
+		if f, ok := functions[t.text]; ok {
+			d.pos += 2
+
+			args := make([]float64, arity[t.text])
+			for i := range args {
+				if i > 0 {
+					if err := d.expect(","); err != nil {
+						return 0, err
+					}
+				}
+
+				v, err := d.expr()
+				if err != nil {
+					return 0, err
+				}
+
+				args[i] = v
+			}
+
+			return f(args), d.expect(")")
+		}
+	case t.kind == tokIdent:
+		if v, ok := d.vars[t.text]; ok {
+			d.next()
+
+			return v, nil
+		}
+
+		if v, ok := colors[strings.ToLower(t.text)]; ok && d.labels[t.text] == nil {
+			d.next()
+
+			return v, nil
+		}
+	}
+
+	if t.kind != tokIdent && !isOrdinal(t) {
+		return 0, d.unexpected()
+	}
+
+	return d.property()
+}
+
+// property reads a property of an object, e.g. A.wid, or a coordinate of a
+// position, e.g. A.ne.x
+func (d *diagram) property() (float64, error) {
+	p, o, err := d.place()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := d.expect("."); err != nil {
+		return 0, err
+	}
+
+	t := d.next()
+
+	switch t.text {
+	case "x":
+		return p.x, nil
+	case "y":
+		return p.y, nil
+	}
+
+	if o == nil {
+		return 0, d.errorf("unknown property %s", t)
+	}
+
+	switch t.text {
+	case "wid", "width":
+		return o.wid, nil
+	case "ht", "height":
+		return o.ht, nil
+	case "rad", "radius":
+		return o.rad, nil
+	case "diameter":
+		return 2 * o.rad, nil
+	case "thickness":
+		return o.thickness, nil
+	case "color":
+		return o.color, nil
+	case "fill":
+		return o.fill, nil
+	}
+
+	return 0, d.errorf("unknown property %s", t)
+}
+
+// position reads a position, returning the object it is the center of, if
+// any, to chop lines at
+func (d *diagram) position() (point, *object, error) {
+	p, o, err := d.positionAtom()
+	if err != nil {
+		return point{}, nil, err
+	}
+
+	for d.is("+") || d.is("-") {
+		sign := 1.0
+		if d.next().text == "-" {
+			sign = -1
+		}
+
+		paren := d.accept("(")
+
+		x, err := d.expr()
+		if err != nil {
+			return point{}, nil, err
+		}
+
+		if err := d.expect(","); err != nil {
+			return point{}, nil, err
+		}
+
+		y, err := d.expr()
+		if err != nil {
+			return point{}, nil, err
+		}
+
+		if paren {
+			if err := d.expect(")"); err != nil {
+				return point{}, nil, err
+			}
+		}
+
+		p, o = p.add(point{x, y}.scale(sign)), nil
+	}
+
+	return p, o, nil
+}
+
+// positionAtom reads a position without offsets: coordinates, a place, or a
+// position relative to others
+func (d *diagram) positionAtom() (point, *object, error) {
+	var p point
+
+	if d.try(func() error {
+		var err error
+		p, err = d.relative()
+
+		return err
+	}) {
+		return p, nil, nil
+	}
+
+	if d.accept("(") {
+		p, o, err := d.position()
+		if err != nil {
+			return point{}, nil, err
+		}
+
+		if d.accept(",") {
+			q, _, err := d.position()
+			if err != nil {
+				return point{}, nil, err
+			}
+
+			p, o = point{p.x, q.y}, nil
+		}
+
+		return p, o, d.expect(")")
+	}
+
+	return d.place()
+}
+
+// relative reads a position starting with an expression: x, y coordinates
+// or a position relative to others
+func (d *diagram) relative() (point, error) {
+	v, err := d.expr()
+	if err != nil {
+		return point{}, err
+	}
+
+	switch {
+	case d.accept(","):
+		y, err := d.expr()
+
+		return point{v, y}, err
+	case d.accept("of"):
+		for _, w := range []string{"the", "way", "between"} {
+			if err := d.expect(w); err != nil {
+				return point{}, err
+			}
+		}
+
+		return d.between(v, "and")
+	case d.accept("between"):
+		return d.between(v, "and")
+	case d.accept("<"):
+		p, err := d.between(v, ",")
+		if err != nil {
+			return point{}, err
+		}
+
+		return p, d.expect(">")
+	case d.accept("above"):
+		p, _, err := d.position()
+
+		return p.add(point{0, v}), err
+	case d.accept("below"):
+		p, _, err := d.position()
+
+		return p.add(point{0, -v}), err
+	case d.is("left") || d.is("right"):
+		dx := v
+		if d.next().text == "left" {
+			dx = -v
+		}
+
+		if err := d.expect("of"); err != nil {
+			return point{}, err
+		}
+
+		p, _, err := d.position()
+
+		return p.add(point{dx, 0}), err
+	}
+
+	return point{}, d.unexpected()
+}
+
+// between reads two positions separated by sep, returning the point at
+// fraction f of the way from the first to the second
+func (d *diagram) between(f float64, sep string) (point, error) {
+	p, _, err := d.positionAtom()
+	if err != nil {
+		return point{}, err
+	}
+
+	if err := d.expect(sep); err != nil {
+		return point{}, err
+	}
+
+	q, _, err := d.positionAtom()
+	if err != nil {
+		return point{}, err
+	}
+
+	return p.add(q.sub(p).scale(f)), nil
+}
+
+// place reads a labelled place, an object, or an edge of an object (e.g.
+// A.ne, last box.s or north of A). Objects are returned with their center.
+func (d *diagram) place() (point, *object, error) {
+	if e, ok := edges[d.peek().text]; ok && d.peek().kind == tokIdent && d.peekAt(1).text == "of" {
+		d.pos += 2
+
+		p, o, err := d.place()
+		if err != nil || o == nil {
+			return p, nil, err
+		}
+
+		return o.edge(e), nil, nil
+	}
+
+	if t := d.peek(); t.kind == tokIdent {
+		if p, ok := d.places[t.text]; ok && d.labels[t.text] == nil {
+			d.next()
+
+			return p, nil, nil
+		}
+	}
+
+	o, err := d.objectRef()
+	if err != nil {
+		return point{}, nil, err
+	}
+
+	if d.is(".") {
+		if e, ok := edges[d.peekAt(1).text]; ok {
+			d.pos += 2
+
+			return o.edge(e), nil, nil
+		}
+	}
+
+	return o.edge(edgeCenter), o, nil
+}
+
+// objectRef reads a reference to an object: its label, or its position
+// among the objects (of a class), e.g. last, previous, 2nd box or 2nd last
+// circle
+func (d *diagram) objectRef() (*object, error) {
+	t := d.peek()
+
+	if t.kind == tokIdent && isLabel(t.text) {
+		o, ok := d.labels[t.text]
+		if !ok {
+			return nil, d.errorf("no object labelled %q", t.text)
+		}
+
+		d.next()
+
+		return o, nil
+	}
+
+	n := 0
+	last := false
+
+	switch {
+	case d.accept("previous"):
+		n, last = 1, true
+	case d.accept("last"):
+		n, last = 1, true
+	case d.accept("first"):
+		n = 1
+	case isOrdinal(t):
+		d.next()
+
+		n = int(t.num)
+		if n < 1 {
+			return nil, d.errorf("bad ordinal %s", t)
+		}
+
+		last = d.accept("last")
+	default:
+		return nil, d.unexpected()
+	}
+
+	var cls *class
+	if c, ok := classes[d.peek().text]; ok && d.peek().kind == tokIdent {
+		d.next()
+
+		cls = c
+	}
+
+	count := 0
+
+	for i := range d.objs {
+		o := d.objs[i]
+		if last {
+			o = d.objs[len(d.objs)-1-i]
+		}
+
+		if cls != nil && o.class != cls {
+			continue
+		}
+
+		if count++; count == n {
+			return o, nil
+		}
+	}
+
+	return nil, d.errorf("no such object")
+}
@@ -0,0 +1,278 @@
+package pikchr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// kinds of tokens
+const (
+	tokEOF = iota
+	tokEnd // end of a statement: newline or ;
+	tokNumber
+	tokString
+	tokIdent
+	tokPunct
+)
+
+// units converts the suffix of a number to inches
+var units = map[string]float64{
+	"in": 1,
+	"cm": 1 / 2.54,
+	"mm": 1 / 25.4,
+	"pt": 1.0 / 72,
+	"px": 1.0 / 96,
+	"pc": 1.0 / 6,
+}
+
+// punctuation, longest first
+var punctuation = []string{
+	"<->", "->", "<-", "+=", "-=", "*=", "/=",
+	"(", ")", ",", ".", "+", "-", "*", "/", "=", ":", "<", ">", "%",
+	"[", "]", "{", "}",
+}
+
+// arrows are the unicode spellings of arrow punctuation
+var arrows = map[rune]string{
+	'←': "<-",
+	'→': "->",
+	'↔': "<->",
+}
+
+type token struct {
+	kind int
+	text string
+	num  float64
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of diagram"
+	case tokEnd:
+		return "end of statement"
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+// lex splits the source of a diagram into tokens, ending with a tokEOF.
+// Comments (#, // and /* */) are skipped, and so are newlines escaped with a
+// backslash.
+func lex(src string) ([]token, error) {
+	tokens := []token{}
+	line := 1
+	pos := 0
+
+	emit := func(kind int, text string, num float64) {
+		tokens = append(tokens, token{kind: kind, text: text, num: num, line: line})
+	}
+
+	for pos < len(src) {
+		ch := src[pos]
+
+		switch {
+		case ch == '\n' || ch == ';':
+			emit(tokEnd, string(ch), 0)
+
+			if ch == '\n' {
+				line++
+			}
+
+			pos++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			pos++
+		case ch == '\\' && pos+1 < len(src) && (src[pos+1] == '\n' || src[pos+1] == '\r'):
+			pos = strings.IndexByte(src[pos:], '\n') + pos + 1
+			line++
+		case ch == '#' || strings.HasPrefix(src[pos:], "//"):
+			for pos < len(src) && src[pos] != '\n' {
+				pos++
+			}
+		case strings.HasPrefix(src[pos:], "/*"):
+			end := strings.Index(src[pos+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+
+			line += strings.Count(src[pos:pos+2+end], "\n")
+			pos += end + 4
+		case ch == '"':
+			end := pos + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+
+				if end < len(src) && src[end] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+
+				end++
+			}
+
+			if end >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+
+			emit(tokString, unescape(src[pos+1:end]), 0)
+			pos = end + 1
+		case isDigit(ch) || (ch == '.' && pos+1 < len(src) && isDigit(src[pos+1])):
+			n, size, err := lexNumber(src[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			emit(tokNumber, src[pos:pos+size], n)
+			pos += size
+		case isIdentStart(ch):
+			end := pos + 1
+			for end < len(src) && isIdentPart(src[end]) {
+				end++
+			}
+
+			emit(tokIdent, src[pos:end], 0)
+			pos = end
+		default:
+			r, size := utf8.DecodeRuneInString(src[pos:])
+			if a, ok := arrows[r]; ok {
+				emit(tokPunct, a, 0)
+				pos += size
+
+				continue
+			}
+
+			matched := false
+
+			for _, p := range punctuation {
+				if strings.HasPrefix(src[pos:], p) {
+					emit(tokPunct, p, 0)
+					pos += len(p)
+					matched = true
+
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+			}
+		}
+	}
+
+	emit(tokEOF, "", 0)
+
+	return tokens, nil
+}
+
+// lexNumber reads a number at the start of s: decimal with an optional
+// exponent and unit, or hexadecimal (e.g. a color, 0xff8000). It returns
+// the number in inches and its length.
+func lexNumber(s string) (float64, int, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		end := 2
+		for end < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[end]) >= 0 {
+			end++
+		}
+
+		n, err := strconv.ParseUint(s[2:end], 16, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad number %q", s[:end])
+		}
+
+		return float64(n), end, nil
+	}
+
+	end := 0
+	for end < len(s) && (isDigit(s[end]) || s[end] == '.') {
+		end++
+	}
+
+	// exponent, unless it starts a word
+	if end < len(s) && (s[end] == 'e' || s[end] == 'E') {
+		exp := end + 1
+		if exp < len(s) && (s[exp] == '+' || s[exp] == '-') {
+			exp++
+		}
+
+		if exp < len(s) && isDigit(s[exp]) {
+			for exp < len(s) && isDigit(s[exp]) {
+				exp++
+			}
+
+			end = exp
+		}
+	}
+
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad number %q", s[:end])
+	}
+
+	if end+2 <= len(s) {
+		if scale, ok := units[s[end:end+2]]; ok && (end+2 == len(s) || !isIdentPart(s[end+2])) {
+			return n * scale, end + 2, nil
+		}
+	}
+
+	// ordinals, e.g. 2nd
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if strings.HasPrefix(s[end:], suffix) && (end+2 == len(s) || !isIdentPart(s[end+2])) {
+			return n, end + 2, nil
+		}
+	}
+
+	return n, end, nil
+}
+
+// unescape resolves the backslash escapes of a string
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch == '$' || ch == '@' || isLetter(ch)
+}
+
+func isIdentPart(ch byte) bool {
+	return ch == '_' || isDigit(ch) || isLetter(ch)
+}
+
+// isOrdinal reports whether a number token is an ordinal, e.g. 2nd
+func isOrdinal(t token) bool {
+	if t.kind != tokNumber || len(t.text) < 3 {
+		return false
+	}
+
+	switch t.text[len(t.text)-2:] {
+	case "st", "nd", "rd", "th":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,319 @@
+package pikchr
+
+import "math"
+
+// directions, in counterclockwise order
+const (
+	dirRight = iota
+	dirUp
+	dirLeft
+	dirDown
+)
+
+var directions = map[string]int{
+	"right": dirRight,
+	"up":    dirUp,
+	"left":  dirLeft,
+	"down":  dirDown,
+}
+
+// unit returns the unit vector of a direction
+func unit(dir int) point {
+	switch dir {
+	case dirUp:
+		return point{0, 1}
+	case dirLeft:
+		return point{-1, 0}
+	case dirDown:
+		return point{0, -1}
+	default:
+		return point{1, 0}
+	}
+}
+
+// point is a position, in inches, with y pointing up
+type point struct {
+	x, y float64
+}
+
+func (p point) add(q point) point {
+	return point{p.x + q.x, p.y + q.y}
+}
+
+func (p point) sub(q point) point {
+	return point{p.x - q.x, p.y - q.y}
+}
+
+func (p point) scale(f float64) point {
+	return point{p.x * f, p.y * f}
+}
+
+func (p point) length() float64 {
+	return math.Hypot(p.x, p.y)
+}
+
+// normal returns the unit vector of p, or the zero vector
+func (p point) normal() point {
+	if l := p.length(); l > 0 {
+		return p.scale(1 / l)
+	}
+
+	return point{}
+}
+
+// edges of objects, as compass points relative to their center, except for
+// the start and end of lines
+const (
+	edgeCenter = iota
+	edgeN
+	edgeNE
+	edgeE
+	edgeSE
+	edgeS
+	edgeSW
+	edgeW
+	edgeNW
+	edgeStart
+	edgeEnd
+)
+
+var edges = map[string]int{
+	"c": edgeCenter, "center": edgeCenter, "centre": edgeCenter,
+	"n": edgeN, "north": edgeN, "t": edgeN, "top": edgeN,
+	"ne": edgeNE, "e": edgeE, "east": edgeE, "r": edgeE, "right": edgeE,
+	"se": edgeSE, "s": edgeS, "south": edgeS, "b": edgeS, "bottom": edgeS,
+	"sw": edgeSW, "w": edgeW, "west": edgeW, "l": edgeW, "left": edgeW,
+	"nw": edgeNW, "start": edgeStart, "end": edgeEnd,
+}
+
+// compass returns the direction of a compass edge, as multiples of the half
+// width and half height
+func compass(edge int) point {
+	switch edge {
+	case edgeN:
+		return point{0, 1}
+	case edgeNE:
+		return point{1, 1}
+	case edgeE:
+		return point{1, 0}
+	case edgeSE:
+		return point{1, -1}
+	case edgeS:
+		return point{0, -1}
+	case edgeSW:
+		return point{-1, -1}
+	case edgeW:
+		return point{-1, 0}
+	case edgeNW:
+		return point{-1, 1}
+	default:
+		return point{}
+	}
+}
+
+// exitEdge returns the edge of a block object facing a direction
+func exitEdge(dir int) int {
+	switch dir {
+	case dirUp:
+		return edgeN
+	case dirLeft:
+		return edgeW
+	case dirDown:
+		return edgeS
+	default:
+		return edgeE
+	}
+}
+
+// shapes of classes
+const (
+	shapeBox = iota
+	shapeCircle
+	shapeEllipse
+	shapeOval
+	shapeCylinder
+	shapeFile
+	shapeDiamond
+	shapeDot
+	shapeText
+	shapeLine
+	shapeSpline
+	shapeArc
+)
+
+// class is a kind of object, e.g. box. Its variables are the names of the
+// variables holding its default width, height and radius, if any.
+type class struct {
+	name            string
+	shape           int
+	wid, ht, rad    string
+	isLine, isInvis bool
+	arrowEnd        bool
+}
+
+var classes = map[string]*class{
+	"box":      {name: "box", shape: shapeBox, wid: "boxwid", ht: "boxht", rad: "boxrad"},
+	"circle":   {name: "circle", shape: shapeCircle, rad: "circlerad"},
+	"ellipse":  {name: "ellipse", shape: shapeEllipse, wid: "ellipsewid", ht: "ellipseht"},
+	"oval":     {name: "oval", shape: shapeOval, wid: "ovalwid", ht: "ovalht"},
+	"cylinder": {name: "cylinder", shape: shapeCylinder, wid: "cylwid", ht: "cylht", rad: "cylrad"},
+	"file":     {name: "file", shape: shapeFile, wid: "filewid", ht: "fileht", rad: "filerad"},
+	"diamond":  {name: "diamond", shape: shapeDiamond, wid: "diamondwid", ht: "diamondht"},
+	"dot":      {name: "dot", shape: shapeDot, rad: "dotrad"},
+	"text":     {name: "text", shape: shapeText, wid: "textwid", ht: "textht", isInvis: true},
+	"line":     {name: "line", shape: shapeLine, isLine: true},
+	"arrow":    {name: "arrow", shape: shapeLine, isLine: true, arrowEnd: true},
+	"spline":   {name: "spline", shape: shapeSpline, isLine: true},
+	"move":     {name: "move", shape: shapeLine, isLine: true, isInvis: true},
+	"arc":      {name: "arc", shape: shapeArc, isLine: true},
+}
+
+// text is a string annotating an object
+type text struct {
+	s                  string
+	above, below       bool
+	ljust, rjust       bool
+	bold, italic, mono bool
+	big, small         int
+}
+
+// object is an object of the diagram. Block objects have a center and a
+// size; lines have a path, through their points.
+type object struct {
+	class *class
+	label string
+
+	center point
+	wid    float64
+	ht     float64
+	rad    float64
+
+	path []point
+	cw   bool
+
+	texts []text
+
+	thickness  float64
+	dash       float64
+	dot        float64
+	fill       float64
+	color      float64
+	invis      bool
+	close      bool
+	arrowStart bool
+	arrowEnd   bool
+
+	// dir is the layout direction when the object was made
+	dir int
+}
+
+// bbox returns the corners of the bounding box of an object, without its
+// annotations
+func (o *object) bbox() (point, point) {
+	if !o.class.isLine {
+		half := point{o.wid / 2, o.ht / 2}
+
+		return o.center.sub(half), o.center.add(half)
+	}
+
+	lo, hi := o.path[0], o.path[0]
+
+	for _, p := range o.path[1:] {
+		lo = point{math.Min(lo.x, p.x), math.Min(lo.y, p.y)}
+		hi = point{math.Max(hi.x, p.x), math.Max(hi.y, p.y)}
+	}
+
+	return lo, hi
+}
+
+// edge returns a point on the edge of an object. Diagonal edges of round
+// objects lie on their outline, and those of rounded boxes on the rounded
+// corner.
+func (o *object) edge(e int) point {
+	if o.class.isLine {
+		switch e {
+		case edgeStart:
+			return o.path[0]
+		case edgeEnd:
+			return o.path[len(o.path)-1]
+		}
+
+		lo, hi := o.bbox()
+		c := lo.add(hi).scale(0.5)
+		d := compass(e)
+
+		return point{c.x + d.x*(hi.x-lo.x)/2, c.y + d.y*(hi.y-lo.y)/2}
+	}
+
+	switch e {
+	case edgeStart:
+		e = exitEdge((o.dir + 2) % 4)
+	case edgeEnd:
+		e = exitEdge(o.dir)
+	}
+
+	d := compass(e)
+	if d.x == 0 || d.y == 0 {
+		return point{o.center.x + d.x*o.wid/2, o.center.y + d.y*o.ht/2}
+	}
+
+	var inset float64
+
+	switch o.class.shape {
+	case shapeCircle, shapeEllipse, shapeDot:
+		return point{o.center.x + d.x*o.wid/2*math.Sqrt2/2, o.center.y + d.y*o.ht/2*math.Sqrt2/2}
+	case shapeDiamond:
+		return point{o.center.x + d.x*o.wid/4, o.center.y + d.y*o.ht/4}
+	case shapeBox:
+		inset = o.rad * (1 - math.Sqrt2/2)
+	case shapeOval:
+		inset = math.Min(o.wid, o.ht) / 2 * (1 - math.Sqrt2/2)
+	}
+
+	return point{o.center.x + d.x*(o.wid/2-inset), o.center.y + d.y*(o.ht/2-inset)}
+}
+
+// chop returns the distance from the center of a block object to its
+// outline, towards p
+func (o *object) chop(p point) float64 {
+	d := p.sub(o.center)
+	if d.length() == 0 {
+		return 0
+	}
+
+	w, h := o.wid/2, o.ht/2
+
+	switch o.class.shape {
+	case shapeCircle, shapeEllipse, shapeDot, shapeOval:
+		// intersection of the ray with the ellipse, close enough for ovals
+		u := d.normal()
+
+		return 1 / math.Sqrt(u.x*u.x/(w*w)+u.y*u.y/(h*h))
+	case shapeDiamond:
+		u := d.normal()
+
+		return 1 / (math.Abs(u.x)/w + math.Abs(u.y)/h)
+	default:
+		u := d.normal()
+		t := math.Inf(1)
+
+		if u.x != 0 {
+			t = math.Min(t, w/math.Abs(u.x))
+		}
+
+		if u.y != 0 {
+			t = math.Min(t, h/math.Abs(u.y))
+		}
+
+		return t
+	}
+}
+
+// move translates an object
+func (o *object) move(d point) {
+	o.center = o.center.add(d)
+
+	for i := range o.path {
+		o.path[i] = o.path[i].add(d)
+	}
+}
@@ -0,0 +1,880 @@
+package pikchr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// defaults are the built-in variables, in inches
+var defaults = map[string]float64{
+	"arcrad":     0.25,
+	"arrowht":    0.08,
+	"arrowwid":   0.06,
+	"boxht":      0.5,
+	"boxrad":     0,
+	"boxwid":     0.75,
+	"charht":     0.14,
+	"charwid":    0.08,
+	"circlerad":  0.25,
+	"color":      0,
+	"cylht":      0.5,
+	"cylrad":     0.075,
+	"cylwid":     0.75,
+	"dashwid":    0.05,
+	"diamondht":  0.75,
+	"diamondwid": 1,
+	"dotrad":     0.015,
+	"ellipseht":  0.5,
+	"ellipsewid": 0.75,
+	"fileht":     0.75,
+	"filerad":    0.15,
+	"filewid":    0.5,
+	"fill":       -1,
+	"lineht":     0.5,
+	"linewid":    0.5,
+	"margin":     0,
+	"movewid":    0.5,
+	"ovalht":     0.5,
+	"ovalwid":    1,
+	"scale":      1,
+	"textht":     0.5,
+	"textwid":    0.75,
+	"thickness":  0.015,
+}
+
+// diagram interprets the statements of a diagram, one at a time, into its
+// objects.
+//
+// * dir: layout direction
+// * cursor: where the next object goes, the exit of the previous one
+// * labels: labelled objects (key: label)
+// * places: labelled positions (key: label)
+type diagram struct {
+	toks []token
+	pos  int
+
+	vars   map[string]float64
+	dir    int
+	cursor point
+	objs   []*object
+	labels map[string]*object
+	places map[string]point
+}
+
+func newDiagram(toks []token) *diagram {
+	vars := make(map[string]float64, len(defaults))
+	for k, v := range defaults {
+		vars[k] = v
+	}
+
+	return &diagram{
+		toks:   toks,
+		vars:   vars,
+		labels: map[string]*object{},
+		places: map[string]point{},
+	}
+}
+
+func (d *diagram) peek() token {
+	return d.toks[d.pos]
+}
+
+// peekAt returns the token i tokens ahead, or the final tokEOF
+func (d *diagram) peekAt(i int) token {
+	if d.pos+i >= len(d.toks) {
+		return d.toks[len(d.toks)-1]
+	}
+
+	return d.toks[d.pos+i]
+}
+
+func (d *diagram) next() token {
+	t := d.toks[d.pos]
+	if t.kind != tokEOF {
+		d.pos++
+	}
+
+	return t
+}
+
+// is reports whether the next token is a word or punctuation
+func (d *diagram) is(s string) bool {
+	t := d.peek()
+
+	return (t.kind == tokIdent || t.kind == tokPunct) && t.text == s
+}
+
+// accept consumes the next token if it is a word or punctuation
+func (d *diagram) accept(s string) bool {
+	if d.is(s) {
+		d.pos++
+
+		return true
+	}
+
+	return false
+}
+
+func (d *diagram) expect(s string) error {
+	if !d.accept(s) {
+		return d.unexpected()
+	}
+
+	return nil
+}
+
+func (d *diagram) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", d.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (d *diagram) unexpected() error {
+	return d.errorf("unexpected %s", d.peek())
+}
+
+// run interprets all statements
+func (d *diagram) run() error {
+	for d.peek().kind != tokEOF {
+		if d.peek().kind == tokEnd {
+			d.next()
+
+			continue
+		}
+
+		if err := d.statement(); err != nil {
+			return err
+		}
+
+		if t := d.peek(); t.kind != tokEnd && t.kind != tokEOF {
+			return d.unexpected()
+		}
+	}
+
+	return nil
+}
+
+func (d *diagram) statement() error {
+	t := d.peek()
+
+	switch {
+	case t.kind == tokPunct && t.text == "[":
+		return d.errorf("sublists are not supported")
+	case t.kind != tokIdent && t.kind != tokString:
+		return d.unexpected()
+	case t.kind == tokIdent && (t.text == "print" || t.text == "assert" || t.text == "define"):
+		return d.errorf("%s is not supported", t.text)
+	}
+
+	if dir, ok := directions[t.text]; ok && t.kind == tokIdent {
+		if n := d.peekAt(1); n.kind == tokEnd || n.kind == tokEOF {
+			d.next()
+			d.dir = dir
+
+			return nil
+		}
+	}
+
+	if n := d.peekAt(1); t.kind == tokIdent && n.kind == tokPunct {
+		switch n.text {
+		case "=", "+=", "-=", "*=", "/=":
+			return d.assign()
+		case ":":
+			if !isLabel(t.text) {
+				return d.errorf("label %q must start with an uppercase letter", t.text)
+			}
+
+			d.pos += 2
+
+			if d.startsObject() {
+				o, err := d.object()
+				if err != nil {
+					return err
+				}
+
+				o.label = t.text
+				d.labels[t.text] = o
+
+				return nil
+			}
+
+			p, _, err := d.position()
+			if err != nil {
+				return err
+			}
+
+			d.places[t.text] = p
+
+			return nil
+		}
+	}
+
+	if !d.startsObject() {
+		return d.unexpected()
+	}
+
+	_, err := d.object()
+
+	return err
+}
+
+// assign sets a variable, e.g. boxwid = 1cm or $n += 1
+func (d *diagram) assign() error {
+	name := d.next().text
+	op := d.next().text
+
+	v, err := d.expr()
+	if err != nil {
+		return err
+	}
+
+	old, ok := d.vars[name]
+	if !ok && op != "=" {
+		return d.errorf("unknown variable %q", name)
+	}
+
+	switch op {
+	case "=":
+		d.vars[name] = v
+	case "+=":
+		d.vars[name] = old + v
+	case "-=":
+		d.vars[name] = old - v
+	case "*=":
+		d.vars[name] = old * v
+	case "/=":
+		if v == 0 {
+			return d.errorf("division by zero")
+		}
+
+		d.vars[name] = old / v
+	}
+
+	return nil
+}
+
+func (d *diagram) startsObject() bool {
+	t := d.peek()
+	if t.kind == tokString {
+		return true
+	}
+
+	_, ok := classes[t.text]
+
+	return t.kind == tokIdent && ok
+}
+
+// segment is a segment of the path of a line: either a relative move, made
+// of directions, or an absolute point
+type segment struct {
+	rel    point
+	abs    *point
+	absObj *object
+	dir    int
+	hasDir bool
+}
+
+// attributes are the attributes of an object, as written
+type attributes struct {
+	segs    []*segment
+	open    bool
+	from    *point
+	fromObj *object
+	at      *point
+	with    int
+
+	wid, ht, rad *float64
+	fit, chop    bool
+	same         bool
+}
+
+// object interprets an object statement and adds the object to the diagram
+func (d *diagram) object() (*object, error) {
+	cls := classes["text"]
+	if d.peek().kind == tokIdent {
+		cls = classes[d.next().text]
+	}
+
+	o := &object{
+		class:     cls,
+		thickness: d.vars["thickness"],
+		color:     d.vars["color"],
+		fill:      d.vars["fill"],
+		invis:     cls.isInvis,
+		arrowEnd:  cls.arrowEnd,
+		dir:       d.dir,
+	}
+
+	if !cls.isLine {
+		o.wid, o.ht, o.rad = d.vars[cls.wid], d.vars[cls.ht], d.vars[cls.rad]
+
+		if cls.shape == shapeCircle || cls.shape == shapeDot {
+			o.wid, o.ht = 2*o.rad, 2*o.rad
+		}
+	}
+
+	a := &attributes{with: edgeCenter}
+
+	for {
+		t := d.peek()
+		if t.kind == tokEnd || t.kind == tokEOF {
+			break
+		}
+
+		if err := d.attribute(o, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.same {
+		d.same(o, a)
+	}
+
+	var err error
+	if cls.isLine {
+		err = d.placeLine(o, a)
+	} else {
+		err = d.placeBlock(o, a)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.objs = append(d.objs, o)
+
+	return o, nil
+}
+
+// textModifiers are the attributes of the last string of an object
+var textModifiers = map[string]func(*text){
+	"above":     func(t *text) { t.above, t.below = true, false },
+	"below":     func(t *text) { t.above, t.below = false, true },
+	"center":    func(t *text) { t.above, t.below, t.ljust, t.rjust = false, false, false, false },
+	"ljust":     func(t *text) { t.ljust, t.rjust = true, false },
+	"rjust":     func(t *text) { t.ljust, t.rjust = false, true },
+	"bold":      func(t *text) { t.bold = true },
+	"italic":    func(t *text) { t.italic = true },
+	"mono":      func(t *text) { t.mono = true },
+	"monospace": func(t *text) { t.mono = true },
+	"big":       func(t *text) { t.big++ },
+	"small":     func(t *text) { t.small++ },
+	"aligned":   func(*text) {},
+}
+
+// attribute interprets the next attribute of an object
+func (d *diagram) attribute(o *object, a *attributes) error {
+	t := d.peek()
+
+	if t.kind == tokString {
+		d.next()
+		o.texts = append(o.texts, text{s: t.text})
+
+		return nil
+	}
+
+	if t.kind == tokPunct {
+		switch t.text {
+		case "->":
+			o.arrowStart, o.arrowEnd = false, true
+		case "<-":
+			o.arrowStart, o.arrowEnd = true, false
+		case "<->":
+			o.arrowStart, o.arrowEnd = true, true
+		default:
+			return d.distance(o, a)
+		}
+
+		d.next()
+
+		return nil
+	}
+
+	if t.kind != tokIdent {
+		return d.distance(o, a)
+	}
+
+	if mod, ok := textModifiers[t.text]; ok && len(o.texts) > 0 {
+		d.next()
+		mod(&o.texts[len(o.texts)-1])
+
+		return nil
+	}
+
+	if dir, ok := directions[t.text]; ok {
+		d.next()
+
+		return d.lengthAttribute(o, a, dir)
+	}
+
+	d.next()
+
+	switch t.text {
+	case "go":
+		if dir, ok := directions[d.peek().text]; ok && d.peek().kind == tokIdent {
+			d.next()
+
+			return d.lengthAttribute(o, a, dir)
+		}
+
+		return d.lengthAttribute(o, a, d.dir)
+	case "then":
+		a.open = false
+
+		if dir, ok := directions[d.peek().text]; ok && d.peek().kind == tokIdent {
+			d.next()
+
+			return d.lengthAttribute(o, a, dir)
+		}
+	case "from":
+		if !o.class.isLine {
+			return d.errorf("%s has no path", o.class.name)
+		}
+
+		p, obj, err := d.position()
+		if err != nil {
+			return err
+		}
+
+		a.from, a.fromObj = &p, obj
+	case "to":
+		if !o.class.isLine {
+			return d.errorf("%s has no path", o.class.name)
+		}
+
+		p, obj, err := d.position()
+		if err != nil {
+			return err
+		}
+
+		a.segs = append(a.segs, &segment{abs: &p, absObj: obj})
+		a.open = false
+	case "at":
+		p, _, err := d.position()
+		if err != nil {
+			return err
+		}
+
+		a.at = &p
+	case "with":
+		d.accept(".")
+
+		e, ok := edges[d.peek().text]
+		if !ok || d.peek().kind != tokIdent {
+			return d.unexpected()
+		}
+
+		d.next()
+
+		if err := d.expect("at"); err != nil {
+			return err
+		}
+
+		p, _, err := d.position()
+		if err != nil {
+			return err
+		}
+
+		a.with, a.at = e, &p
+	case "width", "wid":
+		return d.size(&a.wid, o.wid)
+	case "height", "ht":
+		return d.size(&a.ht, o.ht)
+	case "radius", "rad":
+		return d.size(&a.rad, o.rad)
+	case "diameter":
+		if err := d.size(&a.rad, 2*o.rad); err != nil {
+			return err
+		}
+
+		*a.rad /= 2
+	case "thickness":
+		v, err := d.percent(o.thickness)
+		if err != nil {
+			return err
+		}
+
+		o.thickness = v
+	case "thick":
+		o.thickness *= 1.5
+	case "thin":
+		o.thickness *= 0.67
+	case "invisible", "invis":
+		o.invis = true
+	case "solid":
+		o.invis, o.dash, o.dot = false, 0, 0
+	case "dashed", "dotted":
+		w := d.vars["dashwid"]
+
+		if d.startsExpr() {
+			v, err := d.expr()
+			if err != nil {
+				return err
+			}
+
+			w = v
+		}
+
+		if t.text == "dashed" {
+			o.dash, o.dot = w, 0
+		} else {
+			o.dash, o.dot = 0, w
+		}
+	case "fill":
+		v, err := d.expr()
+		if err != nil {
+			return err
+		}
+
+		o.fill = v
+	case "color":
+		v, err := d.expr()
+		if err != nil {
+			return err
+		}
+
+		o.color = v
+	case "cw":
+		o.cw = true
+	case "ccw":
+		o.cw = false
+	case "close":
+		o.close = true
+	case "chop":
+		a.chop = true
+	case "fit":
+		a.fit = true
+	case "same":
+		a.same = true
+	case "behind":
+		// objects are drawn in order; behind only affects the layering
+		if _, err := d.objectRef(); err != nil {
+			return err
+		}
+	case "heading", "until", "even", "vertex":
+		return d.errorf("%s is not supported", t.text)
+	default:
+		d.pos--
+
+		return d.distance(o, a)
+	}
+
+	return nil
+}
+
+// distance interprets a bare distance, moving a line in the layout direction
+func (d *diagram) distance(o *object, a *attributes) error {
+	if !d.startsExpr() {
+		return d.unexpected()
+	}
+
+	return d.lengthAttribute(o, a, d.dir)
+}
+
+// lengthAttribute interprets a move of a line in a direction, by an
+// optional distance, by default linewid or lineht
+func (d *diagram) lengthAttribute(o *object, a *attributes, dir int) error {
+	if !o.class.isLine {
+		return d.unexpected()
+	}
+
+	def := d.vars["linewid"]
+	if dir == dirUp || dir == dirDown {
+		def = d.vars["lineht"]
+	}
+
+	if o.class.name == "move" {
+		def = d.vars["movewid"]
+	}
+
+	l := def
+
+	if d.startsExpr() {
+		v, err := d.percent(def)
+		if err != nil {
+			return err
+		}
+
+		l = v
+	}
+
+	if !a.open || len(a.segs) == 0 {
+		a.segs = append(a.segs, &segment{})
+		a.open = true
+	}
+
+	s := a.segs[len(a.segs)-1]
+	s.rel = s.rel.add(unit(dir).scale(l))
+	s.dir, s.hasDir = dir, true
+
+	return nil
+}
+
+// knownAttribute reports whether a word starts an attribute
+func (d *diagram) knownAttribute(w string) bool {
+	if _, ok := directions[w]; ok {
+		return true
+	}
+
+	if _, ok := textModifiers[w]; ok {
+		return true
+	}
+
+	switch w {
+	case "go", "then", "from", "to", "at", "with", "width", "wid", "height",
+		"ht", "radius", "rad", "diameter", "thickness", "thick", "thin",
+		"invisible", "invis", "solid", "dashed", "dotted", "fill", "color",
+		"cw", "ccw", "close", "chop", "fit", "same", "behind":
+		return true
+	}
+
+	return false
+}
+
+// size reads a size, e.g. 2cm or 150% of def
+func (d *diagram) size(dst **float64, def float64) error {
+	v, err := d.percent(def)
+	if err != nil {
+		return err
+	}
+
+	*dst = &v
+
+	return nil
+}
+
+// percent reads a distance, or a percentage of def
+func (d *diagram) percent(def float64) (float64, error) {
+	v, err := d.expr()
+	if err != nil {
+		return 0, err
+	}
+
+	if d.accept("%") {
+		return def * v / 100, nil
+	}
+
+	return v, nil
+}
+
+// same copies the size and style of the last object of the same class, and
+// the shape of its path for lines without one
+func (d *diagram) same(o *object, a *attributes) {
+	var prev *object
+
+	for i := len(d.objs) - 1; i >= 0; i-- {
+		if d.objs[i].class == o.class {
+			prev = d.objs[i]
+
+			break
+		}
+	}
+
+	if prev == nil {
+		return
+	}
+
+	o.thickness, o.dash, o.dot = prev.thickness, prev.dash, prev.dot
+	o.fill, o.color, o.invis = prev.fill, prev.color, prev.invis
+
+	if !o.class.isLine {
+		if a.wid == nil {
+			o.wid = prev.wid
+		}
+
+		if a.ht == nil {
+			o.ht = prev.ht
+		}
+
+		if a.rad == nil {
+			o.rad = prev.rad
+		}
+
+		return
+	}
+
+	if len(a.segs) == 0 {
+		for i := 1; i < len(prev.path); i++ {
+			a.segs = append(a.segs, &segment{rel: prev.path[i].sub(prev.path[i-1])})
+		}
+	}
+}
+
+// placeBlock sizes and places a block object, at its position or after the
+// previous object
+func (d *diagram) placeBlock(o *object, a *attributes) error {
+	if a.fit || o.class.shape == shapeText {
+		w, h := d.textSize(o.texts)
+
+		if o.class.shape == shapeText {
+			o.wid, o.ht = w, h
+		} else {
+			o.wid, o.ht = w+2*d.vars["charwid"], h+d.vars["charht"]
+		}
+
+		if o.class.shape == shapeCircle {
+			o.rad = math.Max(o.wid, o.ht) / 2
+			o.wid, o.ht = 2*o.rad, 2*o.rad
+		}
+	}
+
+	if a.rad != nil {
+		o.rad = *a.rad
+
+		if o.class.shape == shapeCircle || o.class.shape == shapeDot {
+			o.wid, o.ht = 2*o.rad, 2*o.rad
+		}
+	}
+
+	if a.wid != nil {
+		o.wid = *a.wid
+
+		if o.class.shape == shapeCircle {
+			o.ht, o.rad = o.wid, o.wid/2
+		}
+	}
+
+	if a.ht != nil {
+		o.ht = *a.ht
+
+		if o.class.shape == shapeCircle {
+			o.wid, o.rad = o.ht, o.ht/2
+		}
+	}
+
+	if o.class.shape == shapeDot && o.fill < 0 {
+		o.fill = o.color
+	}
+
+	if a.at != nil {
+		o.move(a.at.sub(o.edge(a.with)))
+	} else {
+		o.move(d.cursor.sub(o.edge(exitEdge((d.dir + 2) % 4))))
+	}
+
+	d.cursor = o.edge(exitEdge(d.dir))
+
+	return nil
+}
+
+// placeLine builds the path of a line, from its start or the previous
+// object. The direction of its last segment becomes the layout direction.
+func (d *diagram) placeLine(o *object, a *attributes) error {
+	if a.at != nil {
+		return d.errorf("%s can't be placed with at", o.class.name)
+	}
+
+	start := d.cursor
+	if a.from != nil {
+		start = *a.from
+	}
+
+	o.path = []point{start}
+
+	if o.class.shape == shapeArc {
+		end := start
+		if len(a.segs) > 0 && a.segs[len(a.segs)-1].abs != nil {
+			end = *a.segs[len(a.segs)-1].abs
+		} else {
+			turn := 1
+			if o.cw {
+				turn = 3
+			}
+
+			r := d.vars["arcrad"]
+			end = start.add(unit(d.dir).scale(r)).add(unit((d.dir + turn) % 4).scale(r))
+		}
+
+		o.path = append(o.path, end)
+		d.cursor = end
+
+		return nil
+	}
+
+	if len(a.segs) == 0 {
+		def := d.vars["linewid"]
+		if d.dir == dirUp || d.dir == dirDown {
+			def = d.vars["lineht"]
+		}
+
+		if o.class.name == "move" {
+			def = d.vars["movewid"]
+		}
+
+		a.segs = append(a.segs, &segment{rel: unit(d.dir).scale(def), dir: d.dir, hasDir: true})
+	}
+
+	for _, s := range a.segs {
+		last := o.path[len(o.path)-1]
+
+		if s.abs != nil {
+			o.path = append(o.path, *s.abs)
+		} else {
+			o.path = append(o.path, last.add(s.rel))
+		}
+	}
+
+	if a.chop {
+		d.chopLine(o, a)
+	}
+
+	if last := a.segs[len(a.segs)-1]; last.hasDir && last.abs == nil {
+		d.dir = last.dir
+		o.dir = last.dir
+	}
+
+	d.cursor = o.path[len(o.path)-1]
+
+	return nil
+}
+
+// chopLine shortens the ends of a line to the outline of the objects they
+// are at, or by circlerad
+func (d *diagram) chopLine(o *object, a *attributes) {
+	n := len(o.path)
+	r := d.vars["circlerad"]
+
+	startChop := r
+	if a.fromObj != nil && !a.fromObj.class.isLine {
+		startChop = a.fromObj.chop(o.path[1])
+	}
+
+	endChop := r
+	if s := a.segs[len(a.segs)-1]; s.absObj != nil && !s.absObj.class.isLine {
+		endChop = s.absObj.chop(o.path[n-2])
+	}
+
+	o.path[0] = o.path[0].add(o.path[1].sub(o.path[0]).normal().scale(startChop))
+	o.path[n-1] = o.path[n-1].add(o.path[n-2].sub(o.path[n-1]).normal().scale(endChop))
+}
+
+// textSize returns the size of a stack of strings
+func (d *diagram) textSize(texts []text) (float64, float64) {
+	var w float64
+
+	for _, t := range texts {
+		w = math.Max(w, d.textWidth(t))
+	}
+
+	return w, float64(len(texts)) * d.vars["charht"]
+}
+
+// textWidth estimates the width of a string from charwid
+func (d *diagram) textWidth(t text) float64 {
+	w := float64(len([]rune(t.s))) * d.vars["charwid"] * fontScale(t)
+	if t.bold {
+		w *= 1.1
+	}
+
+	return w
+}
+
+// fontScale returns the size of a string relative to the default
+func fontScale(t text) float64 {
+	return math.Pow(1.25, float64(t.big)) * math.Pow(0.8, float64(t.small))
+}
+
+// isLabel reports whether a word can be a label: labels start with an
+// uppercase letter
+func isLabel(w string) bool {
+	return len(w) > 0 && w[0] >= 'A' && w[0] <= 'Z' && !strings.HasPrefix(w, "$")
+}
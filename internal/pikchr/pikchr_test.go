@@ -0,0 +1,102 @@
+package pikchr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"box",
+			`box "a"`,
+			[]string{
+				`viewBox="0 0 110.16 74.16"`,
+				`<path d="M1.08,73.08 L109.08,73.08 L109.08,1.08 L1.08,1.08 Z"`,
+				`<text x="55.08" y="37.08" text-anchor="middle"`,
+				`>a</text>`,
+			},
+		},
+		{
+			"flow",
+			"box; arrow; circle",
+			[]string{
+				`<path d="M109.08,37.08 L175.32,37.08"`,
+				`<polygon points="181.08,37.08 169.56,32.76 169.56,41.4"`,
+				`<circle cx="217.08" cy="37.08" r="36"`,
+			},
+		},
+		{
+			"labels and chop",
+			"A: circle\nB: box at A + (1.5, 0)\nline from A to B chop",
+			[]string{`<path d="M73.08,37.08 L199.08,37.08"`},
+		},
+		{
+			"variables and units",
+			"$w = 2.54cm\nboxwid = $w / 2\nbox",
+			[]string{`viewBox="0 0 74.16 74.16"`},
+		},
+		{
+			"style",
+			`box fill lightgray color 0xff0000 dashed`,
+			[]string{`style="fill:rgb(211,211,211);stroke-width:2.16;stroke:rgb(255,0,0);stroke-dasharray:7.2,7.2;"`},
+		},
+		{
+			"line text above and below",
+			`arrow "x" "y"`,
+			[]string{
+				`y="10.08" text-anchor="middle" fill="rgb(0,0,0)" dominant-baseline="central">x<`,
+				`y="30.24" text-anchor="middle" fill="rgb(0,0,0)" dominant-baseline="central">y<`,
+			},
+		},
+		{
+			"escaped text",
+			`text "<a & b>"`,
+			[]string{`&lt;a &amp; b&gt;</text>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Render([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+
+			for _, w := range tt.want {
+				if !strings.Contains(string(out), w) {
+					t.Errorf("got %s, want it to contain %s", out, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"box wid", "line 1: unexpected end of diagram"},
+		{"box\ncircle at Nope", `line 2: no object labelled "Nope"`},
+		{"[box]", "line 1: sublists are not supported"},
+		{`box "a`, "line 1: unterminated string"},
+		{"box; 3rd box", "line 1: unexpected \"3rd\""},
+		{"line at 1, 2", "line 1: line can't be placed with at"},
+		{"box from 0, 0", "line 1: box has no path"},
+		{"x += 1", `line 1: unknown variable "x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Render([]byte(tt.src))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
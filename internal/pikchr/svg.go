@@ -0,0 +1,432 @@
+package pikchr
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// pixels per inch, at scale 1
+const dpi = 144
+
+// colors are the named colors, case-insensitive, as 0xRRGGBB
+var colors = func() map[string]float64 {
+	m := make(map[string]float64, len(colornames.Map))
+	for name, c := range colornames.Map {
+		m[name] = float64(int(c.R)<<16 | int(c.G)<<8 | int(c.B))
+	}
+
+	return m
+}()
+
+// Render renders a Pikchr diagram to an SVG element
+func Render(src []byte) ([]byte, error) {
+	toks, err := lex(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDiagram(toks)
+	if err := d.run(); err != nil {
+		return nil, err
+	}
+
+	return []byte(d.svg()), nil
+}
+
+// placed is a string at its position
+type placed struct {
+	text
+	at     point
+	anchor string
+}
+
+// svg draws the objects of the diagram, fitting its bounding box
+func (d *diagram) svg() string {
+	texts := make([][]placed, len(d.objs))
+	lo, hi := point{math.Inf(1), math.Inf(1)}, point{math.Inf(-1), math.Inf(-1)}
+
+	include := func(p point) {
+		lo = point{math.Min(lo.x, p.x), math.Min(lo.y, p.y)}
+		hi = point{math.Max(hi.x, p.x), math.Max(hi.y, p.y)}
+	}
+
+	for i, o := range d.objs {
+		l, h := o.bbox()
+		pad := o.thickness / 2
+
+		if o.arrowStart || o.arrowEnd {
+			pad = math.Max(pad, d.vars["arrowwid"]/2)
+		}
+
+		include(l.sub(point{pad, pad}))
+		include(h.add(point{pad, pad}))
+
+		texts[i] = d.placeTexts(o)
+
+		for _, t := range texts[i] {
+			w := d.textWidth(t.text)
+			half := d.vars["charht"] * fontScale(t.text) / 2
+
+			x0 := t.at.x - w/2
+			switch t.anchor {
+			case "start":
+				x0 = t.at.x
+			case "end":
+				x0 = t.at.x - w
+			}
+
+			include(point{x0, t.at.y - half})
+			include(point{x0 + w, t.at.y + half})
+		}
+	}
+
+	if len(d.objs) == 0 {
+		lo, hi = point{}, point{}
+	}
+
+	margin := d.vars["margin"]
+	lo = lo.sub(point{margin, margin})
+	hi = hi.add(point{margin, margin})
+
+	s := &canvas{scale: dpi * d.vars["scale"], lo: lo, hi: hi}
+
+	var b strings.Builder
+
+	w, h := s.length(hi.x-lo.x), s.length(hi.y-lo.y)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" class="pikchr" viewBox="0 0 %s %s" style="max-width:%spx">`+"\n", w, h, w)
+
+	for i, o := range d.objs {
+		if !o.invis {
+			b.WriteString(s.shape(d, o))
+		}
+
+		for _, t := range texts[i] {
+			b.WriteString(s.text(o, t))
+		}
+	}
+
+	b.WriteString("</svg>\n")
+
+	return b.String()
+}
+
+// placeTexts positions the strings of an object: stacked at its center, or
+// above and below it. Two plain strings of a line go above and below it.
+func (d *diagram) placeTexts(o *object) []placed {
+	if len(o.texts) == 0 {
+		return nil
+	}
+
+	texts := o.texts
+	if o.class.isLine && len(texts) == 2 && !texts[0].above && !texts[0].below && !texts[1].above && !texts[1].below {
+		texts = []text{texts[0], texts[1]}
+		texts[0].above, texts[1].below = true, true
+	}
+
+	c := o.center
+	if o.class.isLine {
+		l, h := o.bbox()
+		c = l.add(h).scale(0.5)
+	}
+
+	var above, center, below []text
+
+	for _, t := range texts {
+		switch {
+		case t.above:
+			above = append(above, t)
+		case t.below:
+			below = append(below, t)
+		default:
+			center = append(center, t)
+		}
+	}
+
+	row := d.vars["charht"]
+	out := []placed{}
+
+	place := func(t text, y float64) {
+		p := placed{text: t, at: point{c.x, y}, anchor: "middle"}
+
+		switch {
+		case t.ljust:
+			p.anchor = "start"
+			if !o.class.isLine && o.class.shape != shapeText {
+				p.at.x = c.x - o.wid/2 + d.vars["charwid"]/2
+			}
+		case t.rjust:
+			p.anchor = "end"
+			if !o.class.isLine && o.class.shape != shapeText {
+				p.at.x = c.x + o.wid/2 - d.vars["charwid"]/2
+			}
+		}
+
+		out = append(out, p)
+	}
+
+	top := c.y + float64(len(center))*row/2
+
+	for i, t := range above {
+		place(t, top+(float64(len(above)-1-i)+0.5)*row)
+	}
+
+	for i, t := range center {
+		place(t, top-(float64(i)+0.5)*row)
+	}
+
+	bottom := c.y - float64(len(center))*row/2
+
+	for i, t := range below {
+		place(t, bottom-(float64(i)+0.5)*row)
+	}
+
+	return out
+}
+
+// canvas maps diagram coordinates, in inches with y up, to SVG pixels with
+// y down
+type canvas struct {
+	scale  float64
+	lo, hi point
+}
+
+func (s *canvas) length(l float64) string {
+	return num(l * s.scale)
+}
+
+func (s *canvas) point(p point) string {
+	return num((p.x-s.lo.x)*s.scale) + "," + num((s.hi.y-p.y)*s.scale)
+}
+
+func (s *canvas) x(x float64) string {
+	return num((x - s.lo.x) * s.scale)
+}
+
+func (s *canvas) y(y float64) string {
+	return num((s.hi.y - y) * s.scale)
+}
+
+// num formats a number of pixels
+func num(f float64) string {
+	f = math.Round(f*100) / 100
+	if f == 0 {
+		f = 0 // no -0
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// rgb formats a color, or none
+func rgb(c float64) string {
+	if c < 0 {
+		return "none"
+	}
+
+	n := int(c)
+
+	return fmt.Sprintf("rgb(%d,%d,%d)", n>>16&0xff, n>>8&0xff, n&0xff)
+}
+
+// style returns the style attribute of the outline of an object
+func (s *canvas) style(o *object, fill bool) string {
+	var b strings.Builder
+
+	b.WriteString(`style="fill:`)
+
+	if fill {
+		b.WriteString(rgb(o.fill))
+	} else {
+		b.WriteString("none")
+	}
+
+	b.WriteString(";stroke-width:" + s.length(o.thickness) + ";stroke:" + rgb(o.color) + ";")
+
+	switch {
+	case o.dash > 0:
+		b.WriteString("stroke-dasharray:" + s.length(o.dash) + "," + s.length(o.dash) + ";")
+	case o.dot > 0:
+		b.WriteString("stroke-dasharray:" + s.length(o.thickness) + "," + s.length(o.dot) + ";")
+	}
+
+	b.WriteString(`"`)
+
+	return b.String()
+}
+
+// shape draws the outline of an object
+func (s *canvas) shape(d *diagram, o *object) string {
+	lo, hi := o.bbox()
+	c := o.center
+
+	switch o.class.shape {
+	case shapeBox, shapeOval:
+		r := o.rad
+		if o.class.shape == shapeOval {
+			r = math.Min(o.wid, o.ht) / 2
+		}
+
+		r = math.Min(r, math.Min(o.wid, o.ht)/2)
+		if r <= 0 {
+			return fmt.Sprintf(`<path d="M%s L%s L%s L%s Z" %s />`+"\n",
+				s.point(point{lo.x, lo.y}), s.point(point{hi.x, lo.y}),
+				s.point(point{hi.x, hi.y}), s.point(point{lo.x, hi.y}), s.style(o, true))
+		}
+
+		arc := "A" + s.length(r) + " " + s.length(r) + " 0 0 0 "
+
+		return fmt.Sprintf(`<path d="M%s L%s %s%s L%s %s%s L%s %s%s L%s %s%s Z" %s />`+"\n",
+			s.point(point{lo.x + r, lo.y}), s.point(point{hi.x - r, lo.y}),
+			arc, s.point(point{hi.x, lo.y + r}), s.point(point{hi.x, hi.y - r}),
+			arc, s.point(point{hi.x - r, hi.y}), s.point(point{lo.x + r, hi.y}),
+			arc, s.point(point{lo.x, hi.y - r}), s.point(point{lo.x, lo.y + r}),
+			arc, s.point(point{lo.x + r, lo.y}), s.style(o, true))
+	case shapeCircle, shapeDot:
+		return fmt.Sprintf(`<circle cx="%s" cy="%s" r="%s" %s />`+"\n",
+			s.x(c.x), s.y(c.y), s.length(o.wid/2), s.style(o, true))
+	case shapeEllipse:
+		return fmt.Sprintf(`<ellipse cx="%s" cy="%s" rx="%s" ry="%s" %s />`+"\n",
+			s.x(c.x), s.y(c.y), s.length(o.wid/2), s.length(o.ht/2), s.style(o, true))
+	case shapeCylinder:
+		r := math.Min(o.rad, o.ht/2)
+		arc := "A" + s.length(o.wid/2) + " " + s.length(r) + " 0 0 0 "
+
+		return fmt.Sprintf(`<path d="M%s L%s %s%s L%s %s%s %s%s" %s />`+"\n",
+			s.point(point{lo.x, hi.y - r}), s.point(point{lo.x, lo.y + r}),
+			arc, s.point(point{hi.x, lo.y + r}), s.point(point{hi.x, hi.y - r}),
+			arc, s.point(point{lo.x, hi.y - r}),
+			arc, s.point(point{hi.x, hi.y - r}), s.style(o, true))
+	case shapeFile:
+		r := math.Min(o.rad, math.Min(o.wid, o.ht)/2)
+
+		return fmt.Sprintf(`<path d="M%s L%s L%s L%s L%s Z" %s />`+"\n"+`<path d="M%s L%s L%s" %s />`+"\n",
+			s.point(point{lo.x, lo.y}), s.point(point{hi.x, lo.y}),
+			s.point(point{hi.x, hi.y - r}), s.point(point{hi.x - r, hi.y}),
+			s.point(point{lo.x, hi.y}), s.style(o, true),
+			s.point(point{hi.x - r, hi.y}), s.point(point{hi.x - r, hi.y - r}),
+			s.point(point{hi.x, hi.y - r}), s.style(o, false))
+	case shapeDiamond:
+		return fmt.Sprintf(`<path d="M%s L%s L%s L%s Z" %s />`+"\n",
+			s.point(point{lo.x, c.y}), s.point(point{c.x, lo.y}),
+			s.point(point{hi.x, c.y}), s.point(point{c.x, hi.y}), s.style(o, true))
+	case shapeText:
+		return ""
+	}
+
+	return s.line(d, o)
+}
+
+// line draws a line with its arrowheads, shortening its ends so that they
+// don't poke through
+func (s *canvas) line(d *diagram, o *object) string {
+	path := append([]point{}, o.path...)
+	n := len(path)
+	h, w := d.vars["arrowht"], d.vars["arrowwid"]
+
+	// directions of travel at the start and end
+	startDir, endDir := path[1].sub(path[0]).normal(), path[n-1].sub(path[n-2]).normal()
+
+	if o.class.shape == shapeArc {
+		turn := math.Pi / 4
+		if o.cw {
+			turn = -turn
+		}
+
+		chord := path[1].sub(path[0]).normal()
+		startDir, endDir = rotate(chord, -turn), rotate(chord, turn)
+	}
+
+	var b strings.Builder
+
+	if o.arrowStart {
+		b.WriteString(s.arrowhead(path[0], startDir.scale(-1), h, w, o.color))
+		path[0] = path[0].add(startDir.scale(h / 2))
+	}
+
+	if o.arrowEnd {
+		b.WriteString(s.arrowhead(path[n-1], endDir, h, w, o.color))
+		path[n-1] = path[n-1].sub(endDir.scale(h / 2))
+	}
+
+	var p strings.Builder
+
+	p.WriteString("M" + s.point(path[0]))
+
+	switch {
+	case o.class.shape == shapeArc:
+		r := path[1].sub(path[0]).length() / math.Sqrt2
+		sweep := "0"
+
+		if o.cw {
+			sweep = "1"
+		}
+
+		p.WriteString(" A" + s.length(r) + " " + s.length(r) + " 0 0 " + sweep + " " + s.point(path[1]))
+	case o.class.shape == shapeSpline && n > 2:
+		p.WriteString(" L" + s.point(path[0].add(path[1]).scale(0.5)))
+
+		for i := 1; i < n-1; i++ {
+			p.WriteString(" Q" + s.point(path[i]) + " " + s.point(path[i].add(path[i+1]).scale(0.5)))
+		}
+
+		p.WriteString(" L" + s.point(path[n-1]))
+	default:
+		for _, q := range path[1:] {
+			p.WriteString(" L" + s.point(q))
+		}
+	}
+
+	if o.close {
+		p.WriteString(" Z")
+	}
+
+	return fmt.Sprintf(`<path d="%s" %s />`+"\n", p.String(), s.style(o, o.close)) + b.String()
+}
+
+// arrowhead draws an arrowhead pointing at tip in direction dir
+func (s *canvas) arrowhead(tip, dir point, h, w float64, color float64) string {
+	base := tip.sub(dir.scale(h))
+	side := point{-dir.y, dir.x}.scale(w / 2)
+
+	return fmt.Sprintf(`<polygon points="%s %s %s" style="fill:%s" />`+"\n",
+		s.point(tip), s.point(base.add(side)), s.point(base.sub(side)), rgb(color))
+}
+
+// rotate rotates a vector counterclockwise by an angle, in radians
+func rotate(p point, a float64) point {
+	sin, cos := math.Sincos(a)
+
+	return point{p.x*cos - p.y*sin, p.x*sin + p.y*cos}
+}
+
+// text draws a string of an object
+func (s *canvas) text(o *object, t placed) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="%s" fill="%s" dominant-baseline="central"`,
+		s.x(t.at.x), s.y(t.at.y), t.anchor, rgb(o.color))
+
+	if t.bold {
+		b.WriteString(` font-weight="bold"`)
+	}
+
+	if t.italic {
+		b.WriteString(` font-style="italic"`)
+	}
+
+	if t.mono {
+		b.WriteString(` font-family="monospace"`)
+	}
+
+	if f := fontScale(t.text); f != 1 {
+		b.WriteString(` font-size="` + num(f*100) + `%"`)
+	}
+
+	b.WriteString(">" + html.EscapeString(t.s) + "</text>\n")
+
+	return b.String()
+}
@@ -0,0 +1,251 @@
+// Package redirect handles redirects from the aliases of nodes
+package redirect
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/feed"
+	"github.com/mstcl/pher/v3/internal/graph"
+	"github.com/mstcl/pher/v3/internal/search"
+	"github.com/mstcl/pher/v3/internal/sitemap"
+	"github.com/mstcl/pher/v3/internal/state"
+	"github.com/mstcl/pher/v3/internal/tag"
+)
+
+var Logger *slog.Logger
+
+const (
+	netlifyFilename = "_redirects"
+	nginxFilename   = "redirects.map"
+)
+
+// Redirect is a redirect from an alias to a node.
+//
+// * From: the alias, relative to the output directory and without extension
+//
+// * To: the href of the node
+type Redirect struct {
+	From  string
+	To    string
+	Title string
+}
+
+// Construct collects the redirects of the aliases of all non-draft nodes.
+// Aliases clashing with a node, a generated output or an earlier alias are
+// left out with a warning.
+func Construct(s *state.State) []Redirect {
+	// hrefs: hrefs of all nodes, without extension
+	hrefs := make(map[string]bool)
+	for _, np := range s.NodePaths {
+		hrefs[np.Href(s.InputDir, false)] = true
+	}
+
+	generated := generatedOutputs(s)
+
+	redirects := []Redirect{}
+	seen := make(map[string]string)
+
+	for _, np := range s.NodePaths {
+		entry := s.NodeMap[np]
+		if entry.Metadata.Hidden() {
+			continue
+		}
+
+		for _, alias := range entry.Metadata.Aliases {
+			child := Logger.With(
+				slog.Any("nodepath", np),
+				slog.String("alias", alias),
+				slog.String("context", "redirects"),
+			)
+
+			from := clean(alias)
+			if len(from) == 0 {
+				continue
+			}
+
+			if hrefs[from] {
+				child.Warn("skipping alias: a page already exists there")
+
+				continue
+			}
+
+			if generated[from] || generated[from+".html"] || isArchiveYear(s, from) {
+				child.Warn("skipping alias: a generated file already exists there")
+
+				continue
+			}
+
+			if other, ok := seen[from]; ok {
+				child.Warn("skipping alias: already an alias of another page", slog.String("page", other))
+
+				continue
+			}
+
+			seen[from] = np.String()
+
+			redirects = append(redirects, Redirect{
+				From:  from,
+				To:    np.Href(s.InputDir, false),
+				Title: convert.Title(entry.Metadata.Title, np.Base()),
+			})
+		}
+	}
+
+	sort.SliceStable(redirects, func(i, j int) bool {
+		return redirects[i].From < redirects[j].From
+	})
+
+	return redirects
+}
+
+// generatedOutputs returns the files written to the output directory besides
+// pages and assets, relative to it: tag pages, the archive, the graph, feeds,
+// the search index, the sitemap and robots.txt. Pages of archive years are
+// matched by isArchiveYear instead.
+func generatedOutputs(s *state.State) map[string]bool {
+	outputs := map[string]bool{"tags.html": true}
+
+	for _, t := range s.NodeTags {
+		outputs[tag.Href(t.Name, true)] = true
+
+		if s.Config.TagFeeds && len(s.Config.Feeds) > 0 {
+			outputs[feed.TagFilename(s, t.Name)] = true
+		}
+	}
+
+	for _, c := range s.Config.Feeds {
+		outputs[path.Clean(c.Filename)] = true
+	}
+
+	for np, entry := range s.NodeMap {
+		if np.Base() == "index" && entry.Metadata.Feed && len(s.Config.Feeds) > 0 {
+			outputs[feed.NodegroupFilename(s, np)] = true
+		}
+	}
+
+	if s.Config.Archive.Enable {
+		outputs["archive.html"] = true
+	}
+
+	if s.Config.Graph.Page {
+		outputs["graph.html"] = true
+	}
+
+	if s.Config.Graph.Enable || s.Config.Graph.Page {
+		outputs[graph.Filename] = true
+	}
+
+	if s.Config.Search.Enable {
+		outputs[search.Filename] = true
+	}
+
+	if s.Config.Sitemap {
+		outputs[sitemap.Filename] = true
+	}
+
+	if s.Config.Robots.Enable {
+		outputs[sitemap.RobotsFilename] = true
+	}
+
+	return outputs
+}
+
+// isArchiveYear reports whether from is where the page of a year of the
+// archive goes, e.g. archive/2024
+func isArchiveYear(s *state.State, from string) bool {
+	year, ok := strings.CutPrefix(from, "archive/")
+	if !ok || !s.Config.Archive.Enable || !s.Config.Archive.Years {
+		return false
+	}
+
+	_, err := strconv.Atoi(year)
+
+	return err == nil
+}
+
+// URL returns where the stub of a redirect points to: the absolute url of the
+// node if the site url is set, else its path, percent-encoded
+func URL(s *state.State, r Redirect) string {
+	href := r.To
+	if s.Config.IsExt {
+		href += ".html"
+	}
+
+	href = convert.EscapePath(href)
+
+	if len(s.Config.Url) > 0 {
+		return convert.AbsURL(s.Config.Url, s.Config.Path, href)
+	}
+
+	return path.Join(s.Config.Path, href)
+}
+
+// WriteNetlify outputs the redirects as a Netlify _redirects file
+func WriteNetlify(s *state.State, redirects []Redirect) error {
+	var b strings.Builder
+
+	for _, r := range redirects {
+		fmt.Fprintf(&b, "%s %s 301\n", sitePath(s, r.From), sitePath(s, r.To))
+	}
+
+	return write(s, netlifyFilename, b.String())
+}
+
+// WriteNginx outputs the redirects as entries of an nginx map, to be included
+// in a map block, e.g.
+//
+//	map $uri $redirect { include /path/to/redirects.map; }
+//
+// Keys are quoted but not percent-encoded, since $uri is decoded.
+func WriteNginx(s *state.State, redirects []Redirect) error {
+	var b strings.Builder
+
+	for _, r := range redirects {
+		from := r.From
+		if s.Config.IsExt {
+			from += ".html"
+		}
+
+		fmt.Fprintf(&b, "%q %q;\n", path.Join(s.Config.Path, from), sitePath(s, r.To))
+	}
+
+	return write(s, nginxFilename, b.String())
+}
+
+// sitePath returns the path of href on the site, percent-encoded so it
+// contains no whitespace
+func sitePath(s *state.State, href string) string {
+	if s.Config.IsExt {
+		href += ".html"
+	}
+
+	return path.Join(s.Config.Path, convert.EscapePath(href))
+}
+
+func write(s *state.State, filename string, content string) error {
+	if s.DryRun {
+		return nil
+	}
+
+	if err := os.WriteFile(filepath.Join(s.OutputDir, filename), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// clean normalises an alias to a path relative to the output directory,
+// without extension
+func clean(alias string) string {
+	alias = strings.TrimSuffix(strings.TrimSuffix(filepath.ToSlash(alias), ".md"), ".html")
+
+	return strings.TrimPrefix(path.Clean("/"+alias), "/")
+}
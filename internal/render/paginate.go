@@ -0,0 +1,192 @@
+package render
+
+import (
+	"html/template"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/node"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/nodepathlink"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+// pagination is the position of a page in a paginated listing.
+//
+// * Prev, Next: links to the previous and next pages, empty on the first and
+// last page respectively
+//
+// * Pages: links to every page, in order
+type pagination struct {
+	Prev  string
+	Next  string
+	Pages []pageLink
+	Page  int
+	Total int
+}
+
+// pageLink is a link to a page of a paginated listing
+type pageLink struct {
+	Href    string
+	Number  int
+	Current bool
+}
+
+// urlAttr matches the attributes of html holding a single URL
+var urlAttr = regexp.MustCompile(`\b(href|src)="([^"]*)"`)
+
+// srcsetAttr matches srcset attributes, holding URLs followed by descriptors
+var srcsetAttr = regexp.MustCompile(`\bsrcset="([^"]*)"`)
+
+// pageCount returns the number of pages the listing of entry is split into,
+// 1 if it isn't paginated
+func pageCount(entry node.Node, listing []nodepathlink.NodePathLink) int {
+	per := entry.Metadata.Paginate
+	if entry.Metadata.Layout != "log" || per <= 0 || len(listing) <= per {
+		return 1
+	}
+
+	return (len(listing) + per - 1) / per
+}
+
+// pageOutPath returns the output path of page n > 1 of the listing of the
+// nodegroup index np, at <dir>/page/<n>.html
+func pageOutPath(s *state.State, np nodepath.NodePath, n int) string {
+	return filepath.Join(s.OutputDir, filepath.Dir(np.Href(s.InputDir, false)), "page", strconv.Itoa(n)+".html")
+}
+
+// pageHref returns the link to page n of the listing of the nodegroup index
+// np, relative to the root of the site
+func pageHref(s *state.State, np nodepath.NodePath, n int) string {
+	dir := path.Dir(filepath.ToSlash(np.Href(s.InputDir, false)))
+
+	if n > 1 {
+		href := path.Join(dir, "page", strconv.Itoa(n))
+		if s.Config.IsExt {
+			href += ".html"
+		}
+
+		return href
+	}
+
+	if s.Config.IsExt {
+		return path.Join(dir, "index.html")
+	}
+
+	if dir == "." {
+		return ""
+	}
+
+	return dir + "/"
+}
+
+// paginate splits the listing of d, the data of the nodegroup index np, into
+// pages of per entries. The first page keeps the output path of d; the
+// others are written to <dir>/page/<n>.html, with the relative links of
+// their bodies rebased accordingly.
+func paginate(s *state.State, np nodepath.NodePath, d *data, total int, per int) []*data {
+	links := make([]pageLink, 0, total)
+	for n := 1; n <= total; n++ {
+		href := path.Join(s.Config.Path, pageHref(s, np, n))
+
+		// Keep the trailing slash of directories
+		if n == 1 && !s.Config.IsExt && !strings.HasSuffix(href, "/") {
+			href += "/"
+		}
+
+		links = append(links, pageLink{Href: href, Number: n})
+	}
+
+	pages := make([]*data, 0, total)
+
+	for n := 1; n <= total; n++ {
+		page := *d
+
+		start := (n - 1) * per
+		end := min(start+per, len(d.Listing))
+		page.Listing = d.Listing[start:end]
+
+		p := &pagination{
+			Page:  n,
+			Total: total,
+			Pages: make([]pageLink, total),
+		}
+
+		copy(p.Pages, links)
+		p.Pages[n-1].Current = true
+
+		if n > 1 {
+			p.Prev = links[n-2].Href
+		}
+
+		if n < total {
+			p.Next = links[n].Href
+		}
+
+		page.Pagination = p
+
+		if n > 1 {
+			page.OutFilename = pageOutPath(s, np, n)
+			page.Url = s.Config.Url + pageHref(s, np, n)
+			page.Body = rebase(page.Body)
+
+			page.Listing = make([]nodepathlink.NodePathLink, 0, end-start)
+			for _, l := range d.Listing[start:end] {
+				l.Body = rebase(l.Body)
+				page.Listing = append(page.Listing, l)
+			}
+		}
+
+		page.Mermaid = mermaidScript(s, []byte(page.Body), page.Listing)
+
+		pages = append(pages, &page)
+	}
+
+	return pages
+}
+
+// rebase rewrites the relative links of body for a page one directory
+// deeper, under page/
+func rebase(body template.HTML) template.HTML {
+	b := urlAttr.ReplaceAllStringFunc(string(body), func(m string) string {
+		sub := urlAttr.FindStringSubmatch(m)
+
+		return sub[1] + `="` + rebaseURL(sub[2]) + `"`
+	})
+
+	b = srcsetAttr.ReplaceAllStringFunc(b, func(m string) string {
+		candidates := strings.Split(srcsetAttr.FindStringSubmatch(m)[1], ",")
+
+		for i, c := range candidates {
+			fields := strings.Fields(c)
+			if len(fields) == 0 {
+				continue
+			}
+
+			fields[0] = rebaseURL(fields[0])
+			candidates[i] = strings.Join(fields, " ")
+		}
+
+		return `srcset="` + strings.Join(candidates, ", ") + `"`
+	})
+
+	return template.HTML(b)
+}
+
+// rebaseURL prefixes a relative URL with ../, leaving absolute URLs, rooted
+// paths and fragments as they are
+func rebaseURL(u string) string {
+	if len(u) == 0 || strings.HasPrefix(u, "/") || strings.HasPrefix(u, "#") || strings.HasPrefix(u, "?") {
+		return u
+	}
+
+	if parsed, err := url.Parse(u); err != nil || parsed.IsAbs() {
+		return u
+	}
+
+	return "../" + u
+}
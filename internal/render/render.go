@@ -4,18 +4,31 @@ package render
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log/slog"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/mstcl/pher/v3/internal/archive"
+	"github.com/mstcl/pher/v3/internal/cache"
 	"github.com/mstcl/pher/v3/internal/config"
 	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/feed"
+	"github.com/mstcl/pher/v3/internal/graph"
+	"github.com/mstcl/pher/v3/internal/node"
 	"github.com/mstcl/pher/v3/internal/nodepath"
 	"github.com/mstcl/pher/v3/internal/nodepathlink"
+	"github.com/mstcl/pher/v3/internal/redirect"
+	"github.com/mstcl/pher/v3/internal/search"
 	"github.com/mstcl/pher/v3/internal/state"
 	"github.com/mstcl/pher/v3/internal/tag"
+	"github.com/mstcl/pher/v3/internal/toc"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -28,6 +41,27 @@ var Logger *slog.Logger
 // * Description: body description
 //
 // * Filename: has no extension. Used for navigation crumb.
+//
+// * LiveReload: endpoint to subscribe to for reloads, empty if disabled.
+//
+// * Search: link to the search index, empty if disabled.
+//
+// * Feeds: feeds to link to with <link rel="alternate">.
+//
+// * Mermaid: mermaid module to load, empty if the page has no mermaid diagram
+// or mermaid is disabled.
+//
+// * Graph: link to the graph page, centred on the page if any, empty if
+// disabled.
+//
+// * Tag: the tag of a tag page.
+//
+// * Archive: dated pages by year and month, for the archive pages.
+//
+// * TOC: table of contents, nil unless the page has `toc: true`.
+//
+// * Pagination: position of the page in its paginated listing, nil if the
+// listing isn't paginated.
 type data struct {
 	Body                                     template.HTML
 	Head                                     template.HTML
@@ -46,16 +80,42 @@ type data struct {
 	Ext                                      string
 	OutFilename                              string
 	Path                                     string
+	LiveReload                               string
+	Search                                   string
+	Graph                                    string
+	Mermaid                                  string
 	Tags                                     []string
 	TagsListing                              []tag.Tag
+	Archive                                  []archive.Year
+	Tag                                      *tag.Tag
 	Footer                                   []config.FooterLink
+	Feeds                                    []feed.Alternate
 	Backlinks, Relatedlinks, Crumbs, Listing []nodepathlink.NodePathLink
-	TOC                                      bool
+	TOC                                      *tableOfContents
+	Pagination                               *pagination
 	ShowHeader                               bool
+	NoIndex                                  bool
+}
+
+// tableOfContents is the table of contents of a page.
+//
+// * Aside: it goes in the sidebar rather than in the body
+type tableOfContents struct {
+	Title string
+	Items []tocItem
+	Aside bool
+}
+
+// tocItem is a heading listed in a table of contents, with its subheadings
+type tocItem struct {
+	Title string
+	ID    string
+	Items []tocItem
 }
 
 type renderInput struct {
 	template     *template.Template
+	cache        *cache.Cache
 	data         *data
 	templateName string
 	dryRun       bool
@@ -63,6 +123,20 @@ type renderInput struct {
 
 // Template html with data d.
 func render(i *renderInput) error {
+	// Skip pages whose data hasn't changed since the previous build. The
+	// data holds everything derived from other nodes (backlinks, related
+	// links, listings, tags), so changes to the link graph are caught here.
+	if i.cache != nil {
+		b, err := json.Marshal(i.data)
+		if err != nil {
+			return fmt.Errorf("marshal render data: %w", err)
+		}
+
+		if i.cache.Fresh(i.data.OutFilename, cache.Hash([]byte(i.templateName), b)) {
+			return nil
+		}
+	}
+
 	// Template the current file
 	w := new(bytes.Buffer)
 	if err := i.template.ExecuteTemplate(w, i.templateName, i.data); err != nil {
@@ -84,6 +158,102 @@ func render(i *renderInput) error {
 	return nil
 }
 
+// searchIndex returns the link to the search index, if enabled
+func searchIndex(s *state.State) string {
+	if !s.Config.Search.Enable {
+		return ""
+	}
+
+	return path.Join(s.Config.Path, search.Filename)
+}
+
+// graphPage returns the link to the graph page centred on the node id, or the
+// whole graph if id is empty, if enabled
+func graphPage(s *state.State, id string) string {
+	if !s.Config.Graph.Page {
+		return ""
+	}
+
+	href := path.Join(s.Config.Path, "graph")
+	if s.Config.IsExt {
+		href += ".html"
+	}
+
+	if len(id) > 0 {
+		href += "?n=" + url.QueryEscape(id)
+	}
+
+	return href
+}
+
+// neighbourhood returns the link to the graph page centred on the page of np,
+// if enabled and the page is in the graph
+func neighbourhood(s *state.State, np nodepath.NodePath) string {
+	if !graph.Included(s, np) {
+		return ""
+	}
+
+	return graphPage(s, graph.ID(s, np))
+}
+
+// tableOfContentsOf returns the table of contents of an entry, or nil if it
+// has none or no headings
+func tableOfContentsOf(s *state.State, entry node.Node) *tableOfContents {
+	if !entry.Metadata.TOC || entry.TOC == nil || len(entry.TOC.Items) == 0 {
+		return nil
+	}
+
+	c := s.Config.TOC.For(&entry.Metadata)
+
+	return &tableOfContents{
+		Title: c.Title,
+		Items: tocItems(entry.TOC.Items),
+		Aside: c.Aside(),
+	}
+}
+
+// tocItems converts the items of a toc.TOC
+func tocItems(items toc.Items) []tocItem {
+	converted := make([]tocItem, 0, len(items))
+
+	for _, item := range items {
+		converted = append(converted, tocItem{
+			Title: string(item.Title),
+			ID:    string(item.ID),
+			Items: tocItems(item.Items),
+		})
+	}
+
+	return converted
+}
+
+// mermaidScript returns the mermaid module to load if enabled and body, or
+// the body of an entry of listing, has a mermaid diagram
+func mermaidScript(s *state.State, body []byte, listing []nodepathlink.NodePathLink) string {
+	if !s.Config.Diagrams.Mermaid {
+		return ""
+	}
+
+	script := s.Config.Diagrams.MermaidScript
+	if u, err := url.Parse(script); err == nil && !u.IsAbs() && !strings.HasPrefix(script, "/") {
+		script = path.Join(s.Config.Path, script)
+	}
+
+	marker := []byte(`<pre class="mermaid">`)
+
+	if bytes.Contains(body, marker) {
+		return script
+	}
+
+	for _, l := range listing {
+		if bytes.Contains([]byte(l.Body), marker) {
+			return script
+		}
+	}
+
+	return ""
+}
+
 // Render all files, including tags page, to html.
 func Render(ctx context.Context, s *state.State) error {
 	var err error
@@ -98,7 +268,7 @@ func Render(ctx context.Context, s *state.State) error {
 		eg.Go(func() error {
 			// Don't render drafts or skipped files
 			entry := s.NodeMap[np]
-			if entry.Metadata.Draft || s.SkippedNodePathMap[np] {
+			if entry.Metadata.Hidden() || s.SkippedNodePathMap[np] {
 				return nil
 			}
 
@@ -114,6 +284,22 @@ func Render(ctx context.Context, s *state.State) error {
 			// The output path outDir/{a/b/c/file}.html (part in curly brackets is the href)
 			outPath := s.OutputDir + np.Href(s.InputDir, true) + ".html"
 
+			// Number of pages the listing is split into
+			total := pageCount(entry, s.NodePathLinksMap[np])
+
+			// Leave pages unaffected by a partial build untouched
+			if s.RenderOnly != nil && !s.RenderOnly[np] {
+				if s.Cache != nil {
+					s.Cache.Keep(outPath)
+
+					for n := 2; n <= total; n++ {
+						s.Cache.Keep(pageOutPath(s, np, n))
+					}
+				}
+
+				return nil
+			}
+
 			// Construct rendering data (entryData) from config, entry data, listing, nav
 			// crumbs, etc.
 			entryData := data{
@@ -122,8 +308,9 @@ func Render(ctx context.Context, s *state.State) error {
 				Filename:     np.Base(),
 				Description:  entry.Metadata.Description,
 				Tags:         entry.Metadata.Tags,
-				TOC:          entry.Metadata.TOC,
+				TOC:          tableOfContentsOf(s, entry),
 				ShowHeader:   entry.Metadata.ShowHeader,
+				NoIndex:      entry.Metadata.NoIndex,
 				Layout:       entry.Metadata.Layout,
 				Backlinks:    entry.Backlinks,
 				Relatedlinks: entry.Relatedlinks,
@@ -134,6 +321,11 @@ func Render(ctx context.Context, s *state.State) error {
 				WikiTitle:    s.Config.Title,
 				Url:          s.Config.Url + entry.Href,
 				Path:         s.Config.Path,
+				LiveReload:   s.LiveReload,
+				Search:       searchIndex(s),
+				Graph:        neighbourhood(s, np),
+				Mermaid:      mermaidScript(s, entry.Body, s.NodePathLinksMap[np]),
+				Feeds:        feed.Alternates(s, np),
 				Crumbs:       crumbs,
 				ChromaCSS:    template.CSS(entry.ChromaCSS),
 			}
@@ -147,14 +339,14 @@ func Render(ctx context.Context, s *state.State) error {
 			}
 
 			// Use date only if given
-			entryData.Date, entryData.MachineDate, err = convert.Date(entry.Metadata.Date)
+			entryData.Date, entryData.MachineDate, err = convert.Date(entry.Metadata.Date, s.Config.Location, s.Config.DateFormat)
 			if err != nil {
 				return err
 			}
 
 			// Use data updated only if given
 			entryData.DateUpdated, entryData.MachineDateUpdated, err = convert.Date(
-				entry.Metadata.DateUpdated,
+				entry.Metadata.DateUpdated, s.Config.Location, s.Config.DateFormat,
 			)
 			if err != nil {
 				return err
@@ -165,14 +357,23 @@ func Render(ctx context.Context, s *state.State) error {
 				entryData.TagsListing = s.NodeTags
 			}
 
+			// Split paginated listings across pages
+			pages := []*data{&entryData}
+			if total > 1 {
+				pages = paginate(s, np, &entryData, total, entry.Metadata.Paginate)
+			}
+
 			// Render
-			if err = render(&renderInput{
-				template:     s.Templates,
-				dryRun:       s.DryRun,
-				templateName: "index",
-				data:         &entryData,
-			}); err != nil {
-				return err
+			for _, d := range pages {
+				if err = render(&renderInput{
+					template:     s.Templates,
+					cache:        s.Cache,
+					dryRun:       s.DryRun,
+					templateName: "index",
+					data:         d,
+				}); err != nil {
+					return err
+				}
 			}
 
 			return nil
@@ -185,9 +386,15 @@ func Render(ctx context.Context, s *state.State) error {
 
 	Logger.Debug("finished rendering all files")
 
+	ext := ""
+	if s.Config.IsExt {
+		ext = ".html"
+	}
+
 	// Render tags page
 	if err := render(&renderInput{
 		template:     s.Templates,
+		cache:        s.Cache,
 		dryRun:       s.DryRun,
 		templateName: "tags",
 		data: &data{
@@ -196,6 +403,10 @@ func Render(ctx context.Context, s *state.State) error {
 			TagsListing: s.NodeTags,
 			OutFilename: s.OutputDir + "/tags.html",
 			Path:        s.Config.Path,
+			Ext:         ext,
+			LiveReload:  s.LiveReload,
+			Search:      searchIndex(s),
+			Feeds:       feed.Alternates(s, ""),
 		},
 	}); err != nil {
 		return err
@@ -203,5 +414,165 @@ func Render(ctx context.Context, s *state.State) error {
 
 	Logger.Debug("finished rendering tags page")
 
+	if err := RenderTagPages(s); err != nil {
+		return err
+	}
+
+	Logger.Debug("finished rendering tag pages")
+
+	// Render graph page
+	if s.Config.Graph.Page {
+		if err := render(&renderInput{
+			template:     s.Templates,
+			cache:        s.Cache,
+			dryRun:       s.DryRun,
+			templateName: "graph",
+			data: &data{
+				Title:       "graph",
+				WikiTitle:   s.Config.Title,
+				RootCrumb:   s.Config.RootCrumb,
+				Footer:      s.Config.Footer,
+				OutFilename: s.OutputDir + "/graph.html",
+				Path:        s.Config.Path,
+				LiveReload:  s.LiveReload,
+				Search:      searchIndex(s),
+				Feeds:       feed.Alternates(s, ""),
+			},
+		}); err != nil {
+			return err
+		}
+
+		Logger.Debug("finished rendering graph page")
+	}
+
+	// Render archive pages
+	if s.Config.Archive.Enable {
+		if err := RenderArchive(s, archive.Construct(s)); err != nil {
+			return err
+		}
+
+		Logger.Debug("finished rendering archive pages")
+	}
+
+	return nil
+}
+
+// RenderTagPages renders a page for each tag at tags/<slug>.html, listing
+// its nested tags and tagged pages.
+func RenderTagPages(s *state.State) error {
+	ext := ""
+	if s.Config.IsExt {
+		ext = ".html"
+	}
+
+	for i := range s.NodeTags {
+		t := &s.NodeTags[i]
+
+		feeds := feed.Alternates(s, "")
+		if s.Config.TagFeeds && len(s.Config.Feeds) > 0 {
+			feeds = append(feeds, feed.TagAlternate(s, t.Name))
+		}
+
+		if err := render(&renderInput{
+			template:     s.Templates,
+			cache:        s.Cache,
+			dryRun:       s.DryRun,
+			templateName: "tag",
+			data: &data{
+				Title:       "#" + t.Name,
+				WikiTitle:   s.Config.Title,
+				RootCrumb:   s.Config.RootCrumb,
+				Footer:      s.Config.Footer,
+				Tag:         t,
+				OutFilename: filepath.Join(s.OutputDir, tag.Href(t.Name, true)),
+				Path:        s.Config.Path,
+				Ext:         ext,
+				LiveReload:  s.LiveReload,
+				Search:      searchIndex(s),
+				Feeds:       feeds,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderArchive renders the archive of dated pages, and a page per year if
+// enabled.
+func RenderArchive(s *state.State, years []archive.Year) error {
+	ext := ""
+	if s.Config.IsExt {
+		ext = ".html"
+	}
+
+	archiveData := func(title string, outPath string, years []archive.Year) *data {
+		return &data{
+			Title:       title,
+			Filename:    title,
+			WikiTitle:   s.Config.Title,
+			RootCrumb:   s.Config.RootCrumb,
+			Footer:      s.Config.Footer,
+			Archive:     years,
+			OutFilename: outPath,
+			Path:        s.Config.Path,
+			Ext:         ext,
+			LiveReload:  s.LiveReload,
+			Search:      searchIndex(s),
+			Feeds:       feed.Alternates(s, ""),
+		}
+	}
+
+	if err := render(&renderInput{
+		template:     s.Templates,
+		cache:        s.Cache,
+		dryRun:       s.DryRun,
+		templateName: "archive",
+		data:         archiveData("archive", archive.OutPath(s, 0), years),
+	}); err != nil {
+		return err
+	}
+
+	if !s.Config.Archive.Years {
+		return nil
+	}
+
+	for _, y := range years {
+		if err := render(&renderInput{
+			template:     s.Templates,
+			cache:        s.Cache,
+			dryRun:       s.DryRun,
+			templateName: "archive",
+			data:         archiveData(strconv.Itoa(y.Year), archive.OutPath(s, y.Year), []archive.Year{y}),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderRedirects renders a stub page for each redirect, pointing to its
+// target with a meta refresh and a canonical link.
+func RenderRedirects(s *state.State, redirects []redirect.Redirect) error {
+	for _, r := range redirects {
+		if err := render(&renderInput{
+			template:     s.Templates,
+			cache:        s.Cache,
+			dryRun:       s.DryRun,
+			templateName: "redirect",
+			data: &data{
+				Title:       r.Title,
+				Url:         redirect.URL(s, r),
+				OutFilename: s.OutputDir + "/" + r.From + ".html",
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	Logger.Debug("finished rendering redirects")
+
 	return nil
 }
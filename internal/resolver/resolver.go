@@ -0,0 +1,261 @@
+// Package resolver resolves wikilink targets to source files anywhere in the
+// input directory
+package resolver
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/customanchor"
+	"github.com/mstcl/pher/v3/internal/metadata"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/wikilink"
+)
+
+// Index holds the paths, titles and aliases of all sources. Paths are
+// relative to the input directory, slash-separated and without extension.
+type Index struct {
+	set      map[string]bool
+	dirs     map[string]bool
+	titles   map[string][]string
+	aliases  map[string][]string
+	inputDir string
+	paths    []string
+}
+
+// New indexes the sources of the input directory with their metadata
+func New(inputDir string, nodes map[nodepath.NodePath]metadata.Metadata) *Index {
+	ix := &Index{
+		inputDir: inputDir,
+		set:      make(map[string]bool),
+		dirs:     make(map[string]bool),
+		titles:   make(map[string][]string),
+		aliases:  make(map[string][]string),
+	}
+
+	for np, md := range nodes {
+		p := ix.Rel(np)
+
+		ix.paths = append(ix.paths, p)
+		ix.set[p] = true
+
+		for d := path.Dir(p); d != "."; d = path.Dir(d) {
+			ix.dirs[d] = true
+		}
+
+		if len(md.Title) > 0 {
+			k := strings.ToLower(md.Title)
+			ix.titles[k] = append(ix.titles[k], p)
+		}
+
+		for _, a := range md.Aliases {
+			k := strings.ToLower(clean(a))
+			ix.aliases[k] = append(ix.aliases[k], p)
+		}
+	}
+
+	slices.Sort(ix.paths)
+
+	for _, m := range []map[string][]string{ix.titles, ix.aliases} {
+		for k := range m {
+			slices.Sort(m[k])
+		}
+	}
+
+	return ix
+}
+
+// Resolve returns the source a wikilink target in the source from points
+// to, or an empty nodepath if there is none. Targets with an extension are
+// not sources and are not resolved. Targets are tried, in order:
+//
+//  1. relative to from
+//  2. relative to the input directory
+//  3. as the end of a path anywhere in the input directory, so [[note]]
+//     matches a/b/note.md and [[b/note]] matches it too
+//  4. the above, ignoring case
+//  5. as a title
+//  6. as an alias
+//
+// Generated indexes of nodegroups without index.md are resolved too. If a
+// step matches several sources, the one closest to from is returned along
+// with all the candidates.
+func (ix *Index) Resolve(from nodepath.NodePath, target string) (nodepath.NodePath, []nodepath.NodePath) {
+	raw := strings.TrimSuffix(filepath.ToSlash(target), ".md")
+
+	target = clean(target)
+	if len(target) == 0 || len(path.Ext(target)) > 0 {
+		return "", nil
+	}
+
+	dir := path.Dir(ix.Rel(from))
+
+	for _, fold := range []bool{false, true} {
+		eq := func(a string, b string) bool {
+			if fold {
+				return strings.EqualFold(a, b)
+			}
+
+			return a == b
+		}
+
+		// relative to from, then to the input directory
+		for _, p := range []string{path.Join(dir, raw), target} {
+			if c := ix.match(func(q string) bool { return eq(q, p) }); len(c) > 0 {
+				return ix.pick(dir, c)
+			}
+		}
+
+		// end of a path
+		c := ix.match(func(q string) bool {
+			return eq(q, target) ||
+				(len(q) > len(target) && q[len(q)-len(target)-1] == '/' && eq(q[len(q)-len(target):], target))
+		})
+		if len(c) > 0 {
+			return ix.pick(dir, c)
+		}
+	}
+
+	if c := ix.titles[strings.ToLower(target)]; len(c) > 0 {
+		return ix.pick(dir, c)
+	}
+
+	if c := ix.aliases[strings.ToLower(target)]; len(c) > 0 {
+		return ix.pick(dir, c)
+	}
+
+	// generated indexes
+	for _, p := range []string{path.Join(dir, raw), target} {
+		if path.Base(p) == "index" && (ix.dirs[path.Dir(p)] || path.Dir(p) == ".") {
+			return ix.abs(p), nil
+		}
+	}
+
+	return "", nil
+}
+
+// match returns the indexed paths satisfying f
+func (ix *Index) match(f func(string) bool) []string {
+	var c []string
+
+	for _, p := range ix.paths {
+		if f(p) {
+			c = append(c, p)
+		}
+	}
+
+	return c
+}
+
+// pick returns the candidate closest to dir, or the first one in
+// lexicographical order if several are as close, and all candidates if
+// there are more than one
+func (ix *Index) pick(dir string, candidates []string) (nodepath.NodePath, []nodepath.NodePath) {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if distance(dir, c) < distance(dir, best) {
+			best = c
+		}
+	}
+
+	if len(candidates) == 1 {
+		return ix.abs(best), nil
+	}
+
+	all := make([]nodepath.NodePath, 0, len(candidates))
+	for _, c := range candidates {
+		all = append(all, ix.abs(c))
+	}
+
+	return ix.abs(best), all
+}
+
+// Href returns the link from the page of from to the page of np, as the
+// default wikilink resolver would
+func (ix *Index) Href(from nodepath.NodePath, np nodepath.NodePath) string {
+	rel, _ := filepath.Rel(filepath.Dir(from.String()), strings.TrimSuffix(np.String(), ".md"))
+
+	return filepath.ToSlash(rel) + ".html"
+}
+
+// For returns a wikilink resolver for the source from, linking to resolved
+// sources and falling back to wikilink.DefaultResolver otherwise
+func (ix *Index) For(from nodepath.NodePath) *PageResolver {
+	return &PageResolver{index: ix, from: from, Resolved: make(map[string]string)}
+}
+
+// PageResolver is a wikilink resolver for a single source.
+//
+// * Resolved: the sources resolved targets point to, relative to the input
+// directory (key: target)
+type PageResolver struct {
+	Resolved map[string]string
+	index    *Index
+	from     nodepath.NodePath
+}
+
+var _ wikilink.Resolver = (*PageResolver)(nil) // interface compliance
+
+// ResolveWikilink returns the link to the source the wikilink points to.
+// Fragments are heading IDs, or heading text as in [[page#Heading Text]]:
+// heading IDs are all generated, so customanchor.ID() keeps them and turns
+// text into the ID of its heading.
+func (r *PageResolver) ResolveWikilink(n *wikilink.Node) ([]byte, error) {
+	target := string(n.Target)
+
+	var fragment string
+	if len(n.Fragment) > 0 {
+		fragment = "#" + customanchor.ID(string(n.Fragment))
+	}
+
+	// fragment of the source itself
+	if len(target) == 0 {
+		return []byte(fragment), nil
+	}
+
+	np, _ := r.index.Resolve(r.from, target)
+
+	// unresolved targets are recorded too, in case they resolve later on
+	r.Resolved[target] = r.index.Rel(np)
+
+	if len(np) == 0 {
+		return wikilink.DefaultResolver.ResolveWikilink(n)
+	}
+
+	return []byte(r.index.Href(r.from, np) + fragment), nil
+}
+
+// Rel returns the indexed form of np: relative to the input directory,
+// slash-separated and without extension. It is empty if np is.
+func (ix *Index) Rel(np nodepath.NodePath) string {
+	if len(np) == 0 {
+		return ""
+	}
+
+	rel, _ := filepath.Rel(ix.inputDir, np.String())
+
+	return strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+}
+
+func (ix *Index) abs(p string) nodepath.NodePath {
+	return nodepath.NodePath(filepath.Join(ix.inputDir, filepath.FromSlash(p)) + ".md")
+}
+
+// clean normalises a target or alias to the indexed form
+func clean(target string) string {
+	target = strings.TrimSuffix(filepath.ToSlash(target), ".md")
+
+	return strings.TrimPrefix(path.Clean("/"+target), "/")
+}
+
+// distance counts the path segments between dir and p
+func distance(dir string, p string) int {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return len(p)
+	}
+
+	return strings.Count(rel, "/") + 1
+}
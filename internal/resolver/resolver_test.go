@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mstcl/pher/v3/internal/metadata"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/wikilink"
+)
+
+func TestResolve(t *testing.T) {
+	ix := New("/in", map[nodepath.NodePath]metadata.Metadata{
+		"/in/index.md":       {},
+		"/in/note.md":        {Title: "deep"},
+		"/in/a/note.md":      {},
+		"/in/a/b/deep.md":    {Aliases: []string{"old/shortcut"}},
+		"/in/c/deep.md":      {},
+		"/in/x/Readme.md":    {Title: "Home Note"},
+		"/in/g/sub/page.md":  {},
+		"/in/g/sub/other.md": {},
+	})
+
+	tests := []struct {
+		from       nodepath.NodePath
+		target     string
+		want       nodepath.NodePath
+		candidates []nodepath.NodePath
+	}{
+		// relative to from, then to the input directory
+		{"/in/a/other.md", "note", "/in/a/note.md", nil},
+		{"/in/a/b/x.md", "note", "/in/note.md", nil},
+		{"/in/a/other.md", "../note", "/in/note.md", nil},
+		{"/in/a/other.md", "note.md", "/in/a/note.md", nil},
+		{"/in/a/b/x.md", "deep", "/in/a/b/deep.md", nil},
+
+		// end of a path, closest first, before titles
+		{"/in/index.md", "b/deep", "/in/a/b/deep.md", nil},
+		{"/in/index.md", "deep", "/in/c/deep.md", []nodepath.NodePath{"/in/a/b/deep.md", "/in/c/deep.md"}},
+		{"/in/a/other.md", "deep", "/in/a/b/deep.md", []nodepath.NodePath{"/in/a/b/deep.md", "/in/c/deep.md"}},
+
+		// ignoring case, then titles and aliases
+		{"/in/index.md", "readme", "/in/x/Readme.md", nil},
+		{"/in/index.md", "home note", "/in/x/Readme.md", nil},
+		{"/in/index.md", "Old/Shortcut", "/in/a/b/deep.md", nil},
+
+		// generated indexes
+		{"/in/index.md", "g/index", "/in/g/index.md", nil},
+		{"/in/g/sub/page.md", "../sub/index", "/in/g/sub/index.md", nil},
+		{"/in/g/sub/page.md", "index", "/in/index.md", nil},
+
+		// unresolved
+		{"/in/index.md", "missing", "", nil},
+		{"/in/index.md", "image.png", "", nil},
+		{"/in/index.md", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+tt.target, func(t *testing.T) {
+			got, candidates := ix.Resolve(tt.from, tt.target)
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+
+			if !slices.Equal(candidates, tt.candidates) {
+				t.Errorf("got candidates %v, want %v", candidates, tt.candidates)
+			}
+		})
+	}
+}
+
+func TestHref(t *testing.T) {
+	ix := New("/in", nil)
+
+	tests := []struct {
+		from nodepath.NodePath
+		np   nodepath.NodePath
+		want string
+	}{
+		{"/in/index.md", "/in/a/b.md", "a/b.html"},
+		{"/in/a/b.md", "/in/c.md", "../c.html"},
+		{"/in/a/b.md", "/in/a/c.md", "c.html"},
+	}
+
+	for _, tt := range tests {
+		if got := ix.Href(tt.from, tt.np); got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestResolveWikilink(t *testing.T) {
+	r := New("/in", map[nodepath.NodePath]metadata.Metadata{
+		"/in/a/b.md": {},
+		"/in/c.md":   {},
+	}).For("/in/a/b.md")
+
+	tests := []struct {
+		target   string
+		fragment string
+		want     string
+	}{
+		{"c", "", "../c.html"},
+		{"c", "heading-text", "../c.html#heading-text"},
+		{"c", "Heading Text", "../c.html#heading-text"},
+		{"c", "heading-1", "../c.html#heading-1"},
+		{"", "Heading Text", "#heading-text"},
+		{"missing", "Heading Text", "missing.html#Heading Text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target+"#"+tt.fragment, func(t *testing.T) {
+			got, err := r.ResolveWikilink(&wikilink.Node{Target: []byte(tt.target), Fragment: []byte(tt.fragment)})
+			if err != nil || string(got) != tt.want {
+				t.Errorf("got %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Package search builds the client-side full-text search index
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/cache"
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/nodepath"
+	"github.com/mstcl/pher/v3/internal/state"
+)
+
+var Logger *slog.Logger
+
+const (
+	// Filename is the index, or the shard manifest if the index is sharded
+	Filename = "search.json"
+
+	shardDir = "search"
+)
+
+// Document is a searchable node. Keys are kept short to keep the index
+// compact.
+type Document struct {
+	Title       string   `json:"t"`
+	Href        string   `json:"h"`
+	Description string   `json:"d,omitempty"`
+	Body        string   `json:"b"`
+	Tags        []string `json:"g,omitempty"`
+}
+
+// manifest lists the shards of a sharded index
+type manifest struct {
+	Shards []string `json:"shards"`
+}
+
+var (
+	// contents of these elements aren't text, nor are heading anchors
+	_skipElements = regexp.MustCompile(`(?is)<(script|style|svg|math)\b.*?</(script|style|svg|math)>|<a class="h-anchor"[^>]*>.*?</a>`)
+	_tags         = regexp.MustCompile(`(?s)<[^>]*>`)
+	_spaces       = regexp.MustCompile(`\s+`)
+)
+
+// Text strips html b down to its text content.
+func Text(b []byte) string {
+	b = _skipElements.ReplaceAll(b, []byte(" "))
+	b = _tags.ReplaceAll(b, []byte(" "))
+	s := html.UnescapeString(string(b))
+
+	return strings.TrimSpace(_spaces.ReplaceAllString(s, " "))
+}
+
+// Construct builds the documents of the index, ordered by href.
+func Construct(s *state.State) []Document {
+	docs := []Document{}
+
+	for _, np := range s.NodePaths {
+		entry := s.NodeMap[np]
+		md := entry.Metadata
+
+		if md.Hidden() {
+			continue
+		}
+
+		if md.Unlisted && !s.Config.Search.IncludeUnlisted {
+			continue
+		}
+
+		// Nodes in log nodegroups are only rendered as part of their
+		// nodegroup index, so point there instead
+		target := np
+		if s.SkippedNodePathMap[np] {
+			target = nodepath.NodePath(filepath.Join(filepath.Dir(np.String()), "index.md"))
+		}
+
+		href := target.Href(s.InputDir, false)
+		if s.Config.IsExt {
+			href += ".html"
+		}
+
+		// Untitled nodegroup indexes are named after their directory
+		fallback := np.Base()
+		if fallback == "index" && np != nodepath.NodePath(filepath.Join(s.InputDir, "index.md")) {
+			fallback = filepath.Base(filepath.Dir(np.String()))
+		}
+
+		title := convert.Title(md.Title, fallback)
+
+		docs = append(docs, Document{
+			Title:       title,
+			Href:        href,
+			Description: md.Description,
+			Body:        Text(entry.Body),
+			Tags:        md.Tags,
+		})
+
+		Logger.Debug("indexed node", slog.Any("nodepath", np), slog.String("context", "search index"))
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Href < docs[j].Href
+	})
+
+	return docs
+}
+
+// Write outputs the index to disk, split into the configured number of
+// shards.
+func Write(s *state.State, docs []Document) error {
+	if s.DryRun {
+		return nil
+	}
+
+	shards := s.Config.Search.Shards
+	if shards <= 1 {
+		return writeJSON(s, filepath.Join(s.OutputDir, Filename), docs)
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.OutputDir, shardDir), 0o755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	m := manifest{}
+	size := (len(docs) + shards - 1) / shards
+
+	for i := range shards {
+		lo := min(i*size, len(docs))
+		hi := min(lo+size, len(docs))
+
+		name := shardDir + "/" + strconv.Itoa(i) + ".json"
+		m.Shards = append(m.Shards, name)
+
+		if err := writeJSON(s, filepath.Join(s.OutputDir, filepath.FromSlash(name)), docs[lo:hi]); err != nil {
+			return err
+		}
+	}
+
+	return writeJSON(s, filepath.Join(s.OutputDir, Filename), m)
+}
+
+func writeJSON(s *state.State, outPath string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal search index: %w", err)
+	}
+
+	b = bytes.TrimSpace(b)
+
+	if s.Cache != nil && s.Cache.Fresh(outPath, cache.Hash(b)) {
+		return nil
+	}
+
+	if err := os.WriteFile(outPath, b, 0o644); err != nil {
+		return fmt.Errorf("writing search index: %w", err)
+	}
+
+	return nil
+}
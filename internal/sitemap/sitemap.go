@@ -0,0 +1,150 @@
+// Package sitemap handles sitemap.xml and robots.txt generation
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/convert"
+	"github.com/mstcl/pher/v3/internal/state"
+	"github.com/mstcl/pher/v3/internal/tag"
+)
+
+var Logger *slog.Logger
+
+const (
+	Filename       = "sitemap.xml"
+	RobotsFilename = "robots.txt"
+	ns             = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type URLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	URLs    []URL    `xml:"url"`
+}
+
+type URL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Construct creates the sitemap in memory. Drafts, nodes only rendered as
+// part of a log nodegroup, and nodes marked noindex are left out.
+func Construct(s *state.State) (*URLSet, error) {
+	urlset := &URLSet{Xmlns: ns, URLs: []URL{}}
+
+	for _, np := range s.NodePaths {
+		child := Logger.With(slog.Any("nodepath", np), slog.String("context", "sitemap"))
+
+		entry := s.NodeMap[np]
+		md := entry.Metadata
+
+		if md.Hidden() || md.NoIndex || s.SkippedNodePathMap[np] {
+			continue
+		}
+
+		href := np.Href(s.InputDir, false)
+		if s.Config.IsExt {
+			href += ".html"
+		}
+
+		u := URL{Loc: loc(s, href)}
+
+		// last modified is the updated date, else the date
+		lastMod := md.DateUpdated
+		if len(lastMod) == 0 {
+			lastMod = md.Date
+		}
+
+		if len(lastMod) > 0 {
+			t, err := convert.ParseDate(lastMod, s.Config.Location)
+			if err != nil {
+				return nil, err
+			}
+
+			u.LastMod = t.Format("2006-01-02")
+		}
+
+		urlset.URLs = append(urlset.URLs, u)
+
+		child.Debug("sitemap entry created")
+	}
+
+	tagsHref := "tags"
+	if s.Config.IsExt {
+		tagsHref += ".html"
+	}
+
+	urlset.URLs = append(urlset.URLs, URL{Loc: loc(s, tagsHref)})
+
+	for _, t := range s.NodeTags {
+		urlset.URLs = append(urlset.URLs, URL{Loc: loc(s, tag.Href(t.Name, s.Config.IsExt))})
+	}
+
+	if s.Config.Archive.Enable {
+		archiveHref := "archive"
+		if s.Config.IsExt {
+			archiveHref += ".html"
+		}
+
+		urlset.URLs = append(urlset.URLs, URL{Loc: loc(s, archiveHref)})
+	}
+
+	sort.SliceStable(urlset.URLs, func(i, j int) bool {
+		return urlset.URLs[i].Loc < urlset.URLs[j].Loc
+	})
+
+	return urlset, nil
+}
+
+// Write outputs the sitemap to disk
+func Write(s *state.State, urlset *URLSet) error {
+	if s.DryRun {
+		return nil
+	}
+
+	data, err := xml.MarshalIndent(urlset, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal sitemap: %w", err)
+	}
+
+	b := append([]byte(xml.Header), data...)
+
+	if err := os.WriteFile(filepath.Join(s.OutputDir, Filename), b, 0o644); err != nil {
+		return fmt.Errorf("writing sitemap: %w", err)
+	}
+
+	return nil
+}
+
+// WriteRobots outputs robots.txt to disk, pointing crawlers to the sitemap
+// if there is one
+func WriteRobots(s *state.State) error {
+	if s.DryRun {
+		return nil
+	}
+
+	robots := strings.TrimSpace(s.Config.Robots.Rules) + "\n"
+
+	if s.Config.Sitemap && len(s.Config.Url) > 0 {
+		robots += "\nSitemap: " + loc(s, Filename) + "\n"
+	}
+
+	if err := os.WriteFile(filepath.Join(s.OutputDir, RobotsFilename), []byte(robots), 0o644); err != nil {
+		return fmt.Errorf("writing robots.txt: %w", err)
+	}
+
+	return nil
+}
+
+// loc returns the absolute URL of href, a path relative to the site, with its
+// segments percent-encoded
+func loc(s *state.State, href string) string {
+	return convert.AbsURL(s.Config.Url, s.Config.Path, convert.EscapePath(href))
+}
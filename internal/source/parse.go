@@ -4,10 +4,15 @@ package source
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/mstcl/pher/v3/internal/callout"
 	"github.com/mstcl/pher/v3/internal/customanchor"
+	"github.com/mstcl/pher/v3/internal/diagram"
 	"github.com/mstcl/pher/v3/internal/frontmatter"
+	"github.com/mstcl/pher/v3/internal/imageproc"
+	"github.com/mstcl/pher/v3/internal/mathml"
 	"github.com/mstcl/pher/v3/internal/metadata"
 	"github.com/mstcl/pher/v3/internal/toc"
 	"github.com/mstcl/pher/v3/internal/wikilink"
@@ -18,25 +23,83 @@ import (
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
-	"github.com/yuin/goldmark/util"
 	"go.abhg.dev/goldmark/anchor"
 )
 
+// Links are the links found in a source.
+//
+// * BackLinks: wikilink targets, relative to the source
+//
+// * InternalLinks: image destinations, relative to the source
+//
+// * Refs: every wikilink, image and fragment link, with its line
+//
+// * Headings: IDs of the headings of the source
 type Links struct {
 	BackLinks     []string
 	InternalLinks []string
+	Headings      []string
+	Refs          []Ref
 }
 
+// Kinds of Ref
+const (
+	RefWikilink = "wikilink"
+	RefImage    = "image"
+	RefLink     = "link"
+)
+
+// Ref is a link found in a source.
+//
+// * Target: the wikilink target or image destination, empty for links to a
+// fragment of the source itself
+//
+// * Line: line of the source the link is on, 0 if unknown
+type Ref struct {
+	Kind     string
+	Target   string
+	Fragment string
+	Line     int
+	Embed    bool
+}
+
+// Source is a markdown source file.
+//
+// * Dir: directory of the source file, which relative links are resolved
+// against
+//
+// * Images: if not nil, local images get responsive attributes
+//
+// * Resolver: resolves wikilinks, defaults to wikilink.DefaultResolver
+//
+// * Math: render $...$ and $$...$$ TeX math as MathML
+//
+// * TOC: where and how to add the table of contents, markers are removed
+// if it's added nowhere
 type Source struct {
+	Images        *imageproc.Pipeline
+	Resolver      wikilink.Resolver
 	CodeTheme     string
+	Dir           string
 	Body          []byte
-	TOC           bool
+	TOC           toc.Transformer
 	CodeHighlight bool
+	Math          bool
 }
 
+// Rendered is the result of converting a source to html.
+//
+// * Images: absolute paths of the local images the html depends on
+//
+// * Unsupported: TeX commands of math that couldn't be rendered
+//
+// * TOC: table of contents of the headings within the levels of Source.TOC
 type Rendered struct {
-	HTML      []byte
-	ChromaCSS []byte
+	HTML        []byte
+	ChromaCSS   []byte
+	Images      []string
+	Unsupported []mathml.Unsupported
+	TOC         *toc.TOC
 }
 
 // ExtractMetadata parses metadata (frontmatter) from source.
@@ -65,10 +128,11 @@ func (s *Source) convert(r goldmark.Markdown) ([]byte, *metadata.Metadata, error
 
 	md := metadata.Default()
 
-	// Decode frontmatter
-	d := frontmatter.Get(context)
-	if err := d.Decode(&md); err != nil {
-		return nil, nil, fmt.Errorf("decoding frontmatter: %w", err)
+	// Decode frontmatter, which is optional
+	if d := frontmatter.Get(context); d != nil {
+		if err := d.Decode(&md); err != nil {
+			return nil, nil, fmt.Errorf("decoding frontmatter: %w", err)
+		}
 	}
 
 	return w.Bytes(), md, nil
@@ -77,6 +141,8 @@ func (s *Source) convert(r goldmark.Markdown) ([]byte, *metadata.Metadata, error
 // ToHTML reads in soure code and parse body.
 // Frontmatter is reprocessed to strip it.
 func (s *Source) ToHTML() (*Rendered, error) {
+	tocTransformer := s.TOC
+
 	ext := []goldmark.Extender{
 		&anchor.Extender{
 			Texter: &customanchor.Texter{},
@@ -85,7 +151,10 @@ func (s *Source) ToHTML() (*Rendered, error) {
 			},
 			Position: anchor.Before,
 		},
-		&wikilink.Extender{},
+		&wikilink.Extender{Resolver: s.Resolver},
+		&toc.Extender{Transformer: &tocTransformer},
+		&callout.Extender{},
+		&diagram.Extender{},
 		&frontmatter.Extender{},
 		extension.GFM,
 		extension.Table,
@@ -96,8 +165,16 @@ func (s *Source) ToHTML() (*Rendered, error) {
 		extension.Footnote,
 		extension.Typographer,
 	}
-	if s.TOC {
-		ext = append(ext, &toc.Extender{})
+	var math *mathml.Renderer
+	if s.Math {
+		math = &mathml.Renderer{}
+		ext = append(ext, &mathml.Extender{Renderer: math})
+	}
+
+	var images *imageproc.Transformer
+	if s.Images != nil {
+		images = &imageproc.Transformer{Pipeline: s.Images, Dir: s.Dir}
+		ext = append(ext, &imageproc.Extender{Transformer: images})
 	}
 
 	chromaWriter := new(bytes.Buffer)
@@ -122,45 +199,48 @@ func (s *Source) ToHTML() (*Rendered, error) {
 			fmt.Errorf("convert markdown: %w", err)
 	}
 
-	return &Rendered{
+	rendered := &Rendered{
 		HTML:      body,
 		ChromaCSS: chromaWriter.Bytes(),
-	}, nil
+		TOC:       tocTransformer.TOC,
+	}
+
+	if images != nil {
+		rendered.Images = images.Found
+	}
+
+	if math != nil {
+		rendered.Unsupported = math.Unsupported
+	}
+
+	return rendered, nil
 }
 
-// ExtractLinks walks through all files to collect links within the document.
+// ExtractLinks walks through all files to collect links within the document,
+// and the IDs of its headings.
 func (s *Source) ExtractLinks() (*Links, error) {
-	// internalLinks: internal links
-	var internalLinks []string
-	// backlinks: back links
-	var backlinks []string
-
-	r := text.NewReader(s.Body)
-	wikiLinkParser := wikilink.Parser{}
-
-	// Construct custom parser with lighter options
-	wikilinkParser := util.Prioritized(&wikiLinkParser, 199)
-	linkParser := util.Prioritized(parser.NewLinkParser(), 200)
-	paragraphParser := util.Prioritized(parser.NewParagraphParser(), 1000)
-	listParser := util.Prioritized(parser.NewListParser(), 300)
-	listItemParser := util.Prioritized(parser.NewListItemParser(), 400)
-	BlockquoteParser := util.Prioritized(parser.NewBlockquoteParser(), 800)
-	p := parser.NewParser(parser.WithBlockParsers([]util.PrioritizedValue{
-		paragraphParser,
-		listItemParser,
-		listParser,
-		BlockquoteParser,
-	}...),
-		parser.WithInlineParsers(
-			[]util.PrioritizedValue{
-				wikilinkParser,
-				linkParser,
-			}...),
-		parser.WithParagraphTransformers(),
+	links := &Links{}
+
+	// Parse with the same block parsers as ToHTML so that links in code
+	// blocks and math are left out and heading IDs match
+	ext := []goldmark.Extender{
+		&wikilink.Extender{},
+		&frontmatter.Extender{},
+		extension.GFM,
+		extension.DefinitionList,
+		extension.Footnote,
+	}
+	if s.Math {
+		ext = append(ext, &mathml.Extender{})
+	}
+
+	r := goldmark.New(
+		goldmark.WithExtensions(ext...),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
 	)
 
-	// Parse and walk through nodes to find internal links
-	nodes := p.Parse(r)
+	doc := r.Parser().Parse(text.NewReader(s.Body))
+
 	walker := func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
 			return ast.WalkContinue, nil
@@ -169,10 +249,41 @@ func (s *Source) ExtractLinks() (*Links, error) {
 		switch n := n.(type) {
 		case *ast.Image:
 			dest := string(n.Destination)
-			internalLinks = append(internalLinks, dest)
+			links.InternalLinks = append(links.InternalLinks, dest)
+			links.Refs = append(links.Refs, Ref{
+				Kind:   RefImage,
+				Target: dest,
+				Line:   lineOf(n, s.Body),
+			})
+		case *ast.Link:
+			// only fragments of the document itself
+			dest := string(n.Destination)
+			if strings.HasPrefix(dest, "#") {
+				links.Refs = append(links.Refs, Ref{
+					Kind:     RefLink,
+					Fragment: dest[1:],
+					Line:     lineOf(n, s.Body),
+				})
+			}
 		case *wikilink.Node:
 			target := string(n.Target)
-			backlinks = append(backlinks, target)
+
+			// Markers placing the table of contents aren't links
+			if target == toc.MarkerTarget && !n.Embed {
+				return ast.WalkContinue, nil
+			}
+
+			if len(target) > 0 {
+				links.BackLinks = append(links.BackLinks, target)
+			}
+
+			links.Refs = append(links.Refs, Ref{
+				Kind:     RefWikilink,
+				Target:   target,
+				Fragment: string(n.Fragment),
+				Embed:    n.Embed,
+				Line:     lineOf(n, s.Body),
+			})
 		default:
 			return ast.WalkContinue, nil
 		}
@@ -180,10 +291,59 @@ func (s *Source) ExtractLinks() (*Links, error) {
 		return ast.WalkContinue, nil
 	}
 
-	err := ast.Walk(nodes, walker)
-	if err != nil {
+	if err := ast.Walk(doc, walker); err != nil {
 		return nil, fmt.Errorf("error extracting internal links: %w", err)
 	}
 
-	return &Links{BackLinks: backlinks, InternalLinks: internalLinks}, nil
+	headings, err := toc.Inspect(doc, s.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting headings: %w", err)
+	}
+
+	links.Headings = headingIDs(headings.Items)
+
+	return links, nil
+}
+
+// headingIDs flattens the IDs of a table of contents
+func headingIDs(items toc.Items) []string {
+	ids := []string{}
+
+	for _, item := range items {
+		if len(item.ID) > 0 {
+			ids = append(ids, string(item.ID))
+		}
+
+		ids = append(ids, headingIDs(item.Items)...)
+	}
+
+	return ids
+}
+
+// lineOf returns the line of the source an inline node n is on, going by
+// its first text segment, or else by the first line of its block
+func lineOf(n ast.Node, src []byte) int {
+	offset := -1
+
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			offset = t.Segment.Start
+
+			return ast.WalkStop, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	for p := n; offset < 0 && p != nil; p = p.Parent() {
+		if p.Type() == ast.TypeBlock && p.Lines().Len() > 0 {
+			offset = p.Lines().At(0).Start
+		}
+	}
+
+	if offset < 0 {
+		return 0
+	}
+
+	return bytes.Count(src[:offset], []byte{'\n'}) + 1
 }
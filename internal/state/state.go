@@ -3,12 +3,16 @@ package state
 
 import (
 	"html/template"
+	"time"
 
 	"github.com/mstcl/pher/v3/internal/assetpath"
+	"github.com/mstcl/pher/v3/internal/cache"
 	"github.com/mstcl/pher/v3/internal/config"
+	"github.com/mstcl/pher/v3/internal/git"
 	"github.com/mstcl/pher/v3/internal/node"
 	"github.com/mstcl/pher/v3/internal/nodepath"
 	"github.com/mstcl/pher/v3/internal/nodepathlink"
+	"github.com/mstcl/pher/v3/internal/resolver"
 	"github.com/mstcl/pher/v3/internal/tag"
 )
 
@@ -19,30 +23,93 @@ import (
 // nodegroup is of Log listing type.
 //
 // * NodegroupWithoutIndexMap: map of Nodegroups that don't have an index file
+//
+// * Cache: persisted build cache, nil if incremental builds are disabled.
+//
+// * Resolver: index of all sources to resolve wikilinks against.
+//
+// * EmbedsMap: map of NodePaths to the NodePaths embedded in them, directly
+// or through other embeds.
+//
+// * RenderOnly: if not nil, only these NodePaths are rendered (see Since).
+//
+// * Since: git revision to diff the input directory against, rendering only
+// changed pages and their dependents.
+//
+// * LiveReload: endpoint injected into pages for live reloading, empty
+// outside of serve mode.
+//
+// * Strict: fail the build if there are broken links or missing assets.
+//
+// * CheckFormat: output format of the check command, text or json.
+//
+// * Clock: reference time of the build, which pages are published or expired
+// against.
+//
+// * FileDates: dates of the sources from git or their mtimes, used when their
+// frontmatter has none (see config.Dates).
+//
+// * GitHistory: dates of the files in the history of the commit GitHead,
+// kept across builds (see Reset) as reading it is slow.
+//
+// * BuildFuture, BuildExpired: render pages whose publish date is in the
+// future, or whose expiry date is past.
 type State struct {
 	Config                   *config.Config
+	Cache                    *cache.Cache
+	Resolver                 *resolver.Index
 	Templates                *template.Template
 	NodeMap                  map[nodepath.NodePath]node.Node
 	UserAssetMap             map[assetpath.AssetPath]bool
 	SkippedNodePathMap       map[nodepath.NodePath]bool
 	NodegroupWithoutIndexMap map[nodepath.NodePath]bool
 	NodePathLinksMap         map[nodepath.NodePath][]nodepathlink.NodePathLink
+	RenderOnly               map[nodepath.NodePath]bool
+	EmbedsMap                map[nodepath.NodePath][]nodepath.NodePath
+	FileDates                map[nodepath.NodePath]git.FileDates
+	GitHistory               map[string]git.FileDates
+	Clock                    func() time.Time
+	Command                  string
+	Addr                     string
+	LiveReload               string
+	Since                    string
+	CheckFormat              string
 	InputDir                 string
 	OutputDir                string
 	ConfigFile               string
 	NodePaths                []nodepath.NodePath
 	NodeTags                 []tag.Tag
+	GitHead                  git.Hash
 	ShowVersion              bool
 	Debug                    bool
 	DryRun                   bool
+	Strict                   bool
+	BuildFuture              bool
+	BuildExpired             bool
 }
 
 func Init() State {
-	return State{
-		NodeMap:            make(map[nodepath.NodePath]node.Node),
-		UserAssetMap:       make(map[assetpath.AssetPath]bool),
-		NodePathLinksMap:   make(map[nodepath.NodePath][]nodepathlink.NodePathLink),
-		SkippedNodePathMap: make(map[nodepath.NodePath]bool),
-		NodeTags:           []tag.Tag{},
-	}
+	s := State{Clock: time.Now}
+	s.Reset()
+
+	return s
+}
+
+// Reset clears all computed values, keeping the values parsed from flags and
+// the git history, so the state can be reused for another build.
+func (s *State) Reset() {
+	s.Config = nil
+	s.Cache = nil
+	s.Resolver = nil
+	s.Templates = nil
+	s.NodeMap = make(map[nodepath.NodePath]node.Node)
+	s.UserAssetMap = make(map[assetpath.AssetPath]bool)
+	s.NodePathLinksMap = make(map[nodepath.NodePath][]nodepathlink.NodePathLink)
+	s.SkippedNodePathMap = make(map[nodepath.NodePath]bool)
+	s.NodegroupWithoutIndexMap = nil
+	s.RenderOnly = nil
+	s.EmbedsMap = make(map[nodepath.NodePath][]nodepath.NodePath)
+	s.FileDates = make(map[nodepath.NodePath]git.FileDates)
+	s.NodePaths = nil
+	s.NodeTags = []tag.Tag{}
 }
@@ -0,0 +1,48 @@
+package tag
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Slug returns a URL-safe version of a tag name, used in file names and
+// links. Letters are lowercased, and letters, digits and dashes are kept.
+// Spaces become underscores, and any other character is escaped as ~ and
+// the hex of its UTF-8 bytes, so that distinct names only share a slug if
+// they differ in case. Slashes separating nested tags are kept, and spaces
+// around them trimmed.
+//
+// Slug("Lang/Go Modules") = "lang/go_modules"
+// Slug("C++") = "c~2b~2b"
+func Slug(name string) string {
+	segments := strings.Split(name, "/")
+	slugs := make([]string, 0, len(segments))
+
+	for _, seg := range segments {
+		var b strings.Builder
+
+		for _, r := range strings.ToLower(strings.TrimSpace(seg)) {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-':
+				b.WriteRune(r)
+			case r == ' ':
+				b.WriteByte('_')
+			default:
+				for _, c := range []byte(string(r)) {
+					fmt.Fprintf(&b, "~%02x", c)
+				}
+			}
+		}
+
+		if b.Len() > 0 {
+			slugs = append(slugs, b.String())
+		}
+	}
+
+	if len(slugs) == 0 {
+		return "_"
+	}
+
+	return strings.Join(slugs, "/")
+}
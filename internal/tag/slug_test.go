@@ -0,0 +1,30 @@
+package tag
+
+import "testing"
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Go", "go"},
+		{"C", "c"},
+		{"C++", "c~2b~2b"},
+		{"C#", "c~23"},
+		{"go-modules", "go-modules"},
+		{"go modules", "go_modules"},
+		{"go_modules", "go~5fmodules"},
+		{"Lang/Go Modules", "lang/go_modules"},
+		{" lang // go ", "lang/go"},
+		{"Été", "été"},
+		{"", "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Slug(tt.name); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
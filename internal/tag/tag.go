@@ -1,18 +1,155 @@
 // Package tag defines the Tag struct
 package tag
 
-import "github.com/mstcl/pher/v3/internal/nodepathlink"
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mstcl/pher/v3/internal/nodepathlink"
+)
 
 // Tag is used in extract.extractTags and render.RenderTags. Not to be
 // conceptually confused with parse.Metadata.Tags !!!
 //
-// * Name: tag name
+// * Name: tag name, nested tags are separated by "/", e.g. lang/go
+//
+// * Slug: URL-safe name, the page of the tag is at tags/<slug>.html
+//
+// * Parent: name of the tag this one is nested in, empty for top-level tags
+//
+// * Count: number of entries
 //
-// * Count: number of references
+// * Links: entries (represtend as listing.Listing) tagged with the tag or
+// any tag nested in it
 //
-// * Links: entries (represtend as listing.Listing) for a given tag
+// * Children: tags nested directly in this one, without their Links
+//
+// * Depth: number of tags this one is nested in
 type Tag struct {
-	Name  string
-	Links []nodepathlink.NodePathLink
-	Count int
+	Name     string
+	Slug     string
+	Parent   string
+	Links    []nodepathlink.NodePathLink
+	Children []Tag
+	Count    int
+	Depth    int
+}
+
+// Construct builds the tags from the entries tagged with each name (key: tag
+// name). Nested tags roll up into their ancestors, which exist even if no
+// entry is tagged with them directly. Names with the same slug, which only
+// differ in case (e.g. "Go" and "go"), are the same tag, named after the
+// first of them. Tags are sorted
+// by slug, so that ancestors come before their descendants.
+func Construct(listings map[string][]nodepathlink.NodePathLink) []Tag {
+	names := make([]string, 0, len(listings))
+	for name := range listings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// tags by slug
+	tags := make(map[string]*Tag)
+
+	for _, name := range names {
+		for n := clean(name); len(n) > 0; n = parent(n) {
+			t, ok := tags[Slug(n)]
+			if !ok {
+				t = &Tag{
+					Name:  n,
+					Slug:  Slug(n),
+					Depth: strings.Count(n, "/"),
+				}
+				tags[t.Slug] = t
+			}
+
+			t.Links = appendUnique(t.Links, listings[name])
+		}
+	}
+
+	slugs := make([]string, 0, len(tags))
+	for slug, t := range tags {
+		t.Count = len(t.Links)
+		slugs = append(slugs, slug)
+	}
+
+	sort.Strings(slugs)
+
+	for _, slug := range slugs {
+		t := tags[slug]
+		if t.Depth == 0 {
+			continue
+		}
+
+		p := tags[Slug(parent(t.Name))]
+		t.Parent = p.Name
+		p.Children = append(p.Children, Tag{
+			Name:   t.Name,
+			Slug:   t.Slug,
+			Parent: t.Parent,
+			Count:  t.Count,
+			Depth:  t.Depth,
+		})
+	}
+
+	constructed := make([]Tag, 0, len(slugs))
+	for _, slug := range slugs {
+		constructed = append(constructed, *tags[slug])
+	}
+
+	return constructed
+}
+
+// Href returns the link to the page of the tag name, relative to the root of
+// the site
+func Href(name string, isExt bool) string {
+	href := path.Join("tags", Slug(name))
+	if isExt {
+		href += ".html"
+	}
+
+	return href
+}
+
+// clean removes empty segments of a tag name, e.g. "/lang//go/" is "lang/go"
+func clean(name string) string {
+	segments := []string{}
+
+	for _, seg := range strings.Split(name, "/") {
+		if seg = strings.TrimSpace(seg); len(seg) > 0 {
+			segments = append(segments, seg)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// parent returns the name of the tag name is nested in, empty if none
+func parent(name string) string {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return ""
+	}
+
+	return name[:i]
+}
+
+// appendUnique appends the links of more to links, leaving out those already
+// in links
+func appendUnique(links []nodepathlink.NodePathLink, more []nodepathlink.NodePathLink) []nodepathlink.NodePathLink {
+	seen := make(map[string]bool, len(links))
+	for _, l := range links {
+		seen[l.Href] = true
+	}
+
+	for _, l := range more {
+		if !seen[l.Href] {
+			links = append(links, l)
+			seen[l.Href] = true
+		}
+	}
+
+	return links
 }
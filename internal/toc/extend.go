@@ -27,14 +27,23 @@ import (
 // NOTE: Unless you've supplied your own parser.IDs implementation, you'll
 // need to enable the WithAutoHeadingID option on the parser to generate IDs
 // and links for headings.
-type Extender struct {}
+type Extender struct {
+	// Transformer adding the TOC, defaults to a list of level 2 headings
+	// at the top of the document
+	Transformer *Transformer
+}
 
 // Extend adds support for rendering a table of contents to the provided
 // Markdown parser/renderer.
 func (e *Extender) Extend(md goldmark.Markdown) {
+	t := e.Transformer
+	if t == nil {
+		t = &Transformer{Title: "TOC", MinDepth: 2, MaxDepth: 2, AtMarker: true, AtTop: true}
+	}
+
 	md.Parser().AddOptions(
 		parser.WithASTTransformers(
-			util.Prioritized(&Transformer{}, 100),
+			util.Prioritized(t, 100),
 		),
 	)
 }
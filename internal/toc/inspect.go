@@ -5,12 +5,89 @@ import (
 	"github.com/yuin/goldmark/util"
 )
 
+// InspectOption customizes the behavior of Inspect.
+type InspectOption interface {
+	apply(*inspectOptions)
+}
+
+type inspectOptions struct {
+	minDepth int
+	maxDepth int
+}
+
+// MinDepth limits the depth of the table of contents. Headings with a level
+// lower than the specified depth will be ignored.
+//
+// For example, given the following:
+//
+//	# Foo
+//	## Bar
+//	### Baz
+//	# Quux
+//	## Qux
+//
+// MinDepth(2) will result in the following:
+//
+//	TOC{Items: ...}
+//	 |
+//	 +--- &Item{Title: "Bar", ...}
+//	 |     |
+//	 |     +--- &Item{Title: "Baz", ...}
+//	 |
+//	 +--- &Item{Title: "Qux", ...}
+//
+// Defaults to 0 (no limit) if unspecified or negative.
+func MinDepth(depth int) InspectOption {
+	return minDepthOption(depth)
+}
+
+type minDepthOption int
+
+func (d minDepthOption) apply(o *inspectOptions) {
+	o.minDepth = int(d)
+}
+
+// MaxDepth limits the depth of the table of contents. Headings with a level
+// greater than the specified depth will be ignored.
+//
+// For example, given the following:
+//
+//	# Foo
+//	## Bar
+//	### Baz
+//	# Quux
+//	## Qux
+//
+// MaxDepth(1) will result in the following:
+//
+//	TOC{Items: ...}
+//	 |
+//	 +--- &Item{Title: "Foo", ...}
+//	 |
+//	 +--- &Item{Title: "Quux", ...}
+//
+// Defaults to 0 (no limit) if unspecified or negative.
+func MaxDepth(depth int) InspectOption {
+	return maxDepthOption(depth)
+}
+
+type maxDepthOption int
+
+func (d maxDepthOption) apply(o *inspectOptions) {
+	o.maxDepth = int(d)
+}
+
 // Inspect builds a table of contents by inspecting the provided document.
 //
 // The table of contents is represents as a tree where each item represents a
 // heading or a heading level with zero or more children.
 // The returned TOC will be empty if there are no headings in the document.
-func Inspect(n ast.Node, src []byte) (*TOC, error) {
+func Inspect(n ast.Node, src []byte, options ...InspectOption) (*TOC, error) {
+	var opts inspectOptions
+	for _, opt := range options {
+		opt.apply(&opts)
+	}
+
 	// Appends an empty subitem to the given node
 	// and returns a reference to it.
 	appendChild := func(n *Item) *Item {
@@ -43,11 +120,11 @@ func Inspect(n ast.Node, src []byte) (*TOC, error) {
 			return ast.WalkContinue, nil
 		}
 
-		if 2 > 0 && heading.Level < 2 {
+		if opts.minDepth > 0 && heading.Level < opts.minDepth {
 			return ast.WalkSkipChildren, nil
 		}
 
-		if 2 > 0 && heading.Level > 2 {
+		if opts.maxDepth > 0 && heading.Level > opts.maxDepth {
 			return ast.WalkSkipChildren, nil
 		}
 
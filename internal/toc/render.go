@@ -10,7 +10,7 @@ const _defaultMarker = '*'
 // If the TOC is nil or empty, nil is returned.
 // Do not call Goldmark's renderer if the returned node is nil.
 func RenderList(toc *TOC) ast.Node {
-	return new(ListRenderer).Render(toc)
+	return (&ListRenderer{Title: []byte("TOC")}).Render(toc)
 }
 
 // ListRenderer builds a nested list from a table of contents.
@@ -33,6 +33,9 @@ type ListRenderer struct {
 	//
 	// Defaults to '*'.
 	Marker byte
+
+	// Title heading the list, none if empty.
+	Title []byte
 }
 
 // Render renders the table of contents into Markdown.
@@ -44,10 +47,10 @@ func (r *ListRenderer) Render(toc *TOC) ast.Node {
 		return nil
 	}
 
-	return r.renderItems(toc.Items)
+	return r.renderItems(toc.Items, r.Title)
 }
 
-func (r *ListRenderer) renderItems(items Items) ast.Node {
+func (r *ListRenderer) renderItems(items Items, title []byte) ast.Node {
 	if len(items) == 0 {
 		return nil
 	}
@@ -58,8 +61,9 @@ func (r *ListRenderer) renderItems(items Items) ast.Node {
 	}
 
 	list := ast.NewList(mkr)
-	tocHead := ast.NewString([]byte("TOC"))
-	list.AppendChild(list, tocHead)
+	if len(title) > 0 {
+		list.AppendChild(list, ast.NewString(title))
+	}
 
 	for _, item := range items {
 		list.AppendChild(list, r.renderItem(item))
@@ -87,7 +91,7 @@ func (r *ListRenderer) renderItem(n *Item) ast.Node {
 		}
 	}
 
-	if items := r.renderItems(n.Items); items != nil {
+	if items := r.renderItems(n.Items, nil); items != nil {
 		item.AppendChild(item, items)
 	}
 
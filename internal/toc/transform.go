@@ -1,13 +1,21 @@
 package toc
 
 import (
+	"bytes"
+
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
 )
 
+// Marker is a paragraph placing the TOC by hand.
+const Marker = "[[_TOC_]]"
+
+// MarkerTarget is the wikilink target of Marker, which isn't a link.
+const MarkerTarget = "_TOC_"
+
 // Transformer is a Goldmark AST transformer adds a TOC to the top of a
-// Markdown document.
+// Markdown document, or in place of a Marker.
 //
 // To use this, either install the Extender on the goldmark.Markdown object,
 // or install the AST transformer on the Markdown parser like so.
@@ -23,29 +31,94 @@ import (
 // NOTE: Unless you've supplied your own parser.IDs implementation, you'll
 // need to enable the WithAutoHeadingID option on the parser to generate IDs
 // and links for headings.
-type Transformer struct{}
+type Transformer struct {
+	// Title heading the list, none if empty.
+	Title string
+
+	// MinDepth and MaxDepth limit the levels of the headings listed, see
+	// the options of the same name of Inspect.
+	MinDepth int
+	MaxDepth int
+
+	// AtMarker adds the list in place of the first Marker.
+	AtMarker bool
+
+	// AtTop adds the list to the top of the document, unless it was added
+	// at a Marker.
+	AtTop bool
+
+	// TOC is the table of contents of the document, populated on Transform.
+	TOC *TOC
+}
 
 var _ parser.ASTTransformer = (*Transformer)(nil) // interface compliance
 
 // Transform adds a table of contents to the provided Markdown document.
+// Markers are removed.
 //
 // Errors encountered while transforming are ignored. For more fine-grained
 // control, use Inspect and transform the document manually.
 func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, ctx parser.Context) {
-	toc, err := Inspect(doc, reader.Source())
+	markers := findMarkers(doc, reader.Source())
+
+	toc, err := Inspect(doc, reader.Source(), MinDepth(t.MinDepth), MaxDepth(t.MaxDepth))
 	if err != nil {
 		// There are currently no scenarios under which Inspect
 		// returns an error but we have to account for it anyway.
 		return
 	}
 
+	t.TOC = toc
+
+	var listNode ast.Node
+
 	// Don't add anything for documents with no headings.
-	if len(toc.Items) == 0 {
-		return
+	if len(toc.Items) > 0 {
+		listNode = (&ListRenderer{Title: []byte(t.Title)}).Render(toc)
+		listNode.SetAttributeString("class", []byte("toc"))
 	}
 
-	listNode := RenderList(toc)
-	listNode.SetAttributeString("class", []byte("toc"))
+	for i, m := range markers {
+		if i == 0 && t.AtMarker && listNode != nil {
+			m.Parent().ReplaceChild(m.Parent(), m, listNode)
+			listNode = nil
+
+			continue
+		}
+
+		m.Parent().RemoveChild(m.Parent(), m)
+	}
+
+	if t.AtTop && listNode != nil {
+		doc.InsertBefore(doc, doc.FirstChild(), listNode)
+	}
+}
+
+// findMarkers returns the paragraphs of doc consisting of a Marker
+func findMarkers(doc *ast.Document, src []byte) []ast.Node {
+	markers := []ast.Node{}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		p, ok := n.(*ast.Paragraph)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		lines := p.Lines()
+		if lines.Len() != 1 {
+			return ast.WalkSkipChildren, nil
+		}
+
+		if seg := lines.At(0); string(bytes.TrimSpace(seg.Value(src))) == Marker {
+			markers = append(markers, p)
+		}
+
+		return ast.WalkSkipChildren, nil
+	})
 
-	doc.InsertBefore(doc, doc.FirstChild(), listNode)
+	return markers
 }
@@ -0,0 +1,42 @@
+package wikilink
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// embedPattern matches the placeholders of page embeds, on their own in a
+// paragraph or inline.
+var embedPattern = regexp.MustCompile(`<p><!--pher:embed (\S+) (\S*)--></p>|<!--pher:embed (\S+) (\S*)-->`)
+
+// embedPlaceholder returns the placeholder rendered for an embed of a page
+// (![[page]] or ![[page#heading]]). Pages are rendered independently of each
+// other, so the placeholder is replaced with the content of the page
+// afterwards, see ReplaceEmbeds.
+func embedPlaceholder(target []byte, fragment []byte) string {
+	return fmt.Sprintf(
+		"<!--pher:embed %s %s-->",
+		url.PathEscape(string(target)),
+		url.PathEscape(string(fragment)),
+	)
+}
+
+// ReplaceEmbeds replaces the placeholders of page embeds in html with the
+// result of replace, called with the target and fragment of each embed, and
+// whether the embed is inline, i.e. inside a paragraph.
+func ReplaceEmbeds(html []byte, replace func(target string, fragment string, inline bool) []byte) []byte {
+	return embedPattern.ReplaceAllFunc(html, func(m []byte) []byte {
+		sub := embedPattern.FindSubmatch(m)
+
+		target, fragment, inline := sub[1], sub[2], false
+		if len(target) == 0 {
+			target, fragment, inline = sub[3], sub[4], true
+		}
+
+		t, _ := url.PathUnescape(string(target))
+		f, _ := url.PathUnescape(string(fragment))
+
+		return replace(t, f, inline)
+	})
+}
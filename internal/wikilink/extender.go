@@ -9,7 +9,12 @@ import (
 
 // Extender extends a goldmark Markdown object with support for parsing and
 // rendering Wikilinks.
-type Extender struct {}
+type Extender struct {
+	// Resolver specifies how to resolve destinations for linked pages.
+	//
+	// Uses DefaultResolver if unspecified.
+	Resolver Resolver
+}
 
 // Extend extends the provided Markdown object with support for wikilinks.
 func (e *Extender) Extend(md goldmark.Markdown) {
@@ -26,7 +31,7 @@ func (e *Extender) Extend(md goldmark.Markdown) {
 	md.Renderer().AddOptions(
 		renderer.WithNodeRenderers(
 			util.Prioritized(&Renderer{
-				Resolver: DefaultResolver,
+				Resolver: e.Resolver,
 			}, 199),
 		),
 	)
@@ -8,6 +8,7 @@ import (
 
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/util"
 )
 
@@ -61,8 +62,9 @@ func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
 // using the WithNodeRenderers option.
 //
 // All nodes will be rendered as links (with <a> tags),
-// except for embed links (![[..]]) that refer to images.
-// Those will be rendered as images (with <img> tags).
+// except for embed links (![[..]]) that refer to images or pages.
+// Images will be rendered as images (with <img> tags), and pages as
+// placeholders for ReplaceEmbeds.
 func (r *Renderer) Render(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	r.init()
 
@@ -90,6 +92,12 @@ func (r *Renderer) enter(w util.BufWriter, n *Node, src []byte) (ast.WalkStatus,
 		return ast.WalkContinue, nil
 	}
 
+	if resolveAsPage(n) {
+		_, _ = w.WriteString(embedPlaceholder(n.Target, n.Fragment))
+
+		return ast.WalkSkipChildren, nil
+	}
+
 	img := resolveAsImage(n)
 	if !img {
 		r.hasDest.Store(n, struct{}{})
@@ -115,7 +123,14 @@ func (r *Renderer) enter(w util.BufWriter, n *Node, src []byte) (ast.WalkStatus,
 		}
 	}
 
-	_, _ = w.WriteString(`">`)
+	_, _ = w.WriteString(`"`)
+
+	// Attributes set by AST transformers, e.g. width and height
+	if n.Attributes() != nil {
+		html.RenderAttributes(w, n, html.ImageAttributeFilter)
+	}
+
+	_, _ = w.WriteString(`>`)
 
 	return ast.WalkSkipChildren, nil
 }
@@ -143,3 +158,8 @@ func resolveAsImage(n *Node) bool {
 		return false
 	}
 }
+
+// returns true if the wikilink should be replaced with the content of a page
+func resolveAsPage(n *Node) bool {
+	return n.Embed && len(n.Target) > 0 && len(filepath.Ext(string(n.Target))) == 0
+}
@@ -20,9 +20,14 @@ import (
 	"time"
 
 	"github.com/lmittmann/tint"
+	"github.com/mstcl/pher/v3/internal/archive"
 	"github.com/mstcl/pher/v3/internal/cli"
 	"github.com/mstcl/pher/v3/internal/feed"
+	"github.com/mstcl/pher/v3/internal/graph"
+	"github.com/mstcl/pher/v3/internal/redirect"
 	"github.com/mstcl/pher/v3/internal/render"
+	"github.com/mstcl/pher/v3/internal/search"
+	"github.com/mstcl/pher/v3/internal/sitemap"
 )
 
 //go:embed web/template/* web/static/*
@@ -43,6 +48,11 @@ func main() {
 	cli.Logger = logger
 	render.Logger = logger
 	feed.Logger = logger
+	search.Logger = logger
+	sitemap.Logger = logger
+	redirect.Logger = logger
+	graph.Logger = logger
+	archive.Logger = logger
 
 	if err := cli.Handler(); err != nil {
 		logger.Error(fmt.Sprintf("%v", err))
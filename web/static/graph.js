@@ -0,0 +1,133 @@
+// Draws the link graph written by pher: the neighbourhood of the note given
+// by ?n= (up to ?depth= links away, default 1), or the whole graph.
+(() => {
+  const svg = document.querySelector("svg.graph");
+  if (!svg) return;
+
+  const ns = "http://www.w3.org/2000/svg";
+  const base = svg.dataset.path.replace(/\/$/, "");
+  const params = new URLSearchParams(location.search);
+  const centre = params.get("n");
+  const depth = Number(params.get("depth")) || 1;
+  const width = 800;
+  const height = 600;
+
+  // nodes within depth links of the centre, following edges both ways
+  const neighbourhood = (graph) => {
+    if (!centre) return graph;
+
+    const adjacent = new Map();
+    for (const e of graph.edges) {
+      if (!adjacent.has(e.source)) adjacent.set(e.source, []);
+      if (!adjacent.has(e.target)) adjacent.set(e.target, []);
+      adjacent.get(e.source).push(e.target);
+      adjacent.get(e.target).push(e.source);
+    }
+
+    const keep = new Set([centre]);
+    let frontier = [centre];
+    for (let d = 0; d < depth; d++) {
+      const next = [];
+      for (const id of frontier) {
+        for (const other of adjacent.get(id) || []) {
+          if (!keep.has(other)) {
+            keep.add(other);
+            next.push(other);
+          }
+        }
+      }
+      frontier = next;
+    }
+
+    return {
+      nodes: graph.nodes.filter((n) => keep.has(n.id)),
+      edges: graph.edges.filter((e) => keep.has(e.source) && keep.has(e.target)),
+    };
+  };
+
+  // simple force-directed layout: nodes repel, edges pull, all drift to the
+  // middle
+  const layout = (nodes, edges) => {
+    const byId = new Map(nodes.map((n, i) => {
+      const a = (2 * Math.PI * i) / nodes.length;
+      n.x = width / 2 + (width / 4) * Math.cos(a);
+      n.y = height / 2 + (height / 4) * Math.sin(a);
+      return [n.id, n];
+    }));
+
+    const k = Math.sqrt((width * height) / Math.max(nodes.length, 1)) / 2;
+    for (let step = 0, t = width / 10; step < 300; step++, t *= 0.98) {
+      for (const n of nodes) n.dx = (width / 2 - n.x) * 0.01, n.dy = (height / 2 - n.y) * 0.01;
+
+      for (const a of nodes) {
+        for (const b of nodes) {
+          if (a === b) continue;
+          const dx = a.x - b.x || 0.01;
+          const dy = a.y - b.y || 0.01;
+          const d2 = dx * dx + dy * dy;
+          a.dx += (dx * k * k) / d2;
+          a.dy += (dy * k * k) / d2;
+        }
+      }
+
+      for (const e of edges) {
+        const a = byId.get(e.source);
+        const b = byId.get(e.target);
+        const dx = a.x - b.x;
+        const dy = a.y - b.y;
+        const d = Math.sqrt(dx * dx + dy * dy) || 0.01;
+        const f = d / k;
+        a.dx -= dx * f, a.dy -= dy * f;
+        b.dx += dx * f, b.dy += dy * f;
+      }
+
+      for (const n of nodes) {
+        const d = Math.sqrt(n.dx * n.dx + n.dy * n.dy) || 1;
+        n.x += (n.dx / d) * Math.min(d, t);
+        n.y += (n.dy / d) * Math.min(d, t);
+        n.x = Math.min(width - 20, Math.max(20, n.x));
+        n.y = Math.min(height - 20, Math.max(20, n.y));
+      }
+    }
+
+    return byId;
+  };
+
+  const el = (name, attrs) => {
+    const e = document.createElementNS(ns, name);
+    for (const [k, v] of Object.entries(attrs)) e.setAttribute(k, v);
+    return e;
+  };
+
+  const draw = ({ nodes, edges }) => {
+    const byId = layout(nodes, edges);
+    svg.setAttribute("viewBox", `0 0 ${width} ${height}`);
+
+    for (const e of edges) {
+      const a = byId.get(e.source);
+      const b = byId.get(e.target);
+      svg.append(el("line", { class: `graph-edge graph-${e.type}`, x1: a.x, y1: a.y, x2: b.x, y2: b.y }));
+    }
+
+    for (const n of nodes) {
+      const a = el("a", { href: `${base}/${n.href}` });
+      a.append(
+        el("circle", {
+          class: `graph-node graph-${n.type}${n.id === centre ? " graph-centre" : ""}`,
+          cx: n.x,
+          cy: n.y,
+          r: n.id === centre ? 7 : 5,
+        }),
+      );
+
+      const label = el("text", { class: "graph-label", x: n.x + 8, y: n.y + 4 });
+      label.textContent = n.title;
+      a.append(label);
+      svg.append(a);
+    }
+  };
+
+  fetch(svg.dataset.graph)
+    .then((res) => res.json())
+    .then((graph) => draw(neighbourhood(graph)));
+})();
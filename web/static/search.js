@@ -0,0 +1,104 @@
+// Client-side full-text search over the index written by pher.
+(() => {
+  const input = document.querySelector(".search-input");
+  if (!input) return;
+
+  const results = document.querySelector(".search-results");
+  const base = input.dataset.path.replace(/\/$/, "");
+  const maxResults = 20;
+
+  let docs = null;
+
+  // fetch the index (and its shards, if any) on first use
+  const load = async () => {
+    if (docs) return docs;
+
+    const res = await fetch(input.dataset.index);
+    const index = await res.json();
+
+    if (Array.isArray(index)) {
+      docs = index;
+    } else {
+      const shards = await Promise.all(
+        index.shards.map((s) => fetch(`${base}/${s}`).then((r) => r.json())),
+      );
+      docs = shards.flat();
+    }
+
+    return docs;
+  };
+
+  // every term must match somewhere, matches in titles and tags weigh more
+  const score = (doc, terms) => {
+    const title = doc.t.toLowerCase();
+    const tags = (doc.g || []).join(" ").toLowerCase();
+    const desc = (doc.d || "").toLowerCase();
+    const body = doc.b.toLowerCase();
+
+    let total = 0;
+    for (const term of terms) {
+      let s = 0;
+      if (title.includes(term)) s += 10;
+      if (tags.includes(term)) s += 5;
+      if (desc.includes(term)) s += 3;
+      if (body.includes(term)) s += 1;
+      if (s === 0) return 0;
+      total += s;
+    }
+
+    return total;
+  };
+
+  // short excerpt of the body around the first term
+  const snippet = (doc, terms) => {
+    const body = doc.b;
+    const at = body.toLowerCase().indexOf(terms[0]);
+    if (at < 0) return doc.d || body.slice(0, 120);
+
+    const start = Math.max(0, at - 40);
+    return (start > 0 ? "…" : "") + body.slice(start, at + 80) + "…";
+  };
+
+  const render = (matches, terms) => {
+    results.replaceChildren(
+      ...matches.map((doc) => {
+        const li = document.createElement("li");
+        const a = document.createElement("a");
+        a.href = `${base}/${doc.h}`;
+        a.textContent = doc.t;
+
+        const p = document.createElement("span");
+        p.className = "search-snippet";
+        p.textContent = snippet(doc, terms);
+
+        li.append(a, p);
+        return li;
+      }),
+    );
+  };
+
+  input.addEventListener("input", async () => {
+    const terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    if (terms.length === 0) {
+      results.replaceChildren();
+      return;
+    }
+
+    const all = await load();
+    const matches = all
+      .map((doc) => [score(doc, terms), doc])
+      .filter(([s]) => s > 0)
+      .sort((a, b) => b[0] - a[0])
+      .slice(0, maxResults)
+      .map(([, doc]) => doc);
+
+    render(matches, terms);
+  });
+
+  input.addEventListener("keydown", (e) => {
+    if (e.key === "Escape") {
+      input.value = "";
+      results.replaceChildren();
+    }
+  });
+})();